@@ -15,6 +15,13 @@ import (
 
 type Cmd struct {
 	Compile compile.Compile `cmd:"" default:"withargs" help:""`
+	GenGo   compile.GenGo   `cmd:"" name:"gen-go" help:"Compile a script into a Go file with a Play function."`
+	Build   compile.Build   `cmd:"" help:"Build an executable that plays a script."`
+}
+
+// StandaloneCmd is used instead of Cmd by executables made with build --standalone.
+type StandaloneCmd struct {
+	Play compile.Standalone `cmd:"" default:"withargs" help:"Play the embedded script."`
 }
 
 func main() {
@@ -32,9 +39,17 @@ func main() {
 
 func Run(ctx context.Context, stdout io.Writer, args []string) error {
 	var exit bool
-	var cmd Cmd
+	var cmd any = &Cmd{}
+	// Executables made with build --standalone play their script, anything else
+	// including errors reading the executable falls back to the normal commands
+	if executable, err := os.Executable(); err == nil {
+		if embedded, ok, err := compile.ReadEmbeddedScript(executable); err == nil && ok {
+			cmd = &StandaloneCmd{Play: compile.Standalone{Embedded: embedded}}
+		}
+	}
+
 	parser, err := kong.New(
-		&cmd,
+		cmd,
 		kong.Exit(func(_ int) { exit = true }),
 		kong.BindTo(ctx, new(context.Context)),
 		kong.BindTo(stdout, new(io.Writer)),
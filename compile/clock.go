@@ -0,0 +1,85 @@
+package compile
+
+import (
+	"io"
+	"time"
+)
+
+// Clock is the source of time for a running program.
+type Clock interface {
+	Now() time.Time
+	After(duration time.Duration) <-chan time.Time
+}
+
+// RealClock waits in real time.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+// VirtualClock jumps forward instead of waiting so programs run instantly.
+// It is not safe for concurrent use.
+type VirtualClock struct {
+	start time.Time
+	now   time.Time
+}
+
+func NewVirtualClock() *VirtualClock {
+	start := time.Unix(0, 0).UTC()
+	return &VirtualClock{
+		start: start,
+		now:   start,
+	}
+}
+
+func (clock *VirtualClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *VirtualClock) After(duration time.Duration) <-chan time.Time {
+	clock.now = clock.now.Add(max(duration, 0))
+	after := make(chan time.Time, 1)
+	after <- clock.now
+	return after
+}
+
+// Elapsed is the total time waited so far.
+func (clock *VirtualClock) Elapsed() time.Duration {
+	return clock.now.Sub(clock.start)
+}
+
+// TimedWrite is a single write and how far into the program it happened.
+type TimedWrite struct {
+	At   time.Duration
+	Data string
+}
+
+// Recorder is an io.Writer that records when every write happened on a VirtualClock.
+type Recorder struct {
+	Clock  *VirtualClock
+	Writes []TimedWrite
+}
+
+func (recorder *Recorder) Write(p []byte) (int, error) {
+	recorder.Writes = append(recorder.Writes, TimedWrite{
+		At:   recorder.Clock.Elapsed(),
+		Data: string(p),
+	})
+	return len(p), nil
+}
+
+// String is everything written so far.
+func (recorder *Recorder) String() string {
+	var data []byte
+	for _, write := range recorder.Writes {
+		data = append(data, write.Data...)
+	}
+	return string(data)
+}
+
+var _ io.Writer = &Recorder{}
@@ -0,0 +1,133 @@
+package compile
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ColorProfile is how many colors a terminal supports.
+type ColorProfile int
+
+const (
+	ProfileNone ColorProfile = iota
+	Profile16
+	Profile256
+	ProfileTrueColor
+)
+
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// DetectColor picks the color profile for writer following NO_COLOR, FORCE_COLOR, TERM and COLORTERM.
+// mode is one of auto, always or never.
+func DetectColor(writer io.Writer, mode string, getenv func(string) string) ColorProfile {
+	switch mode {
+	case ColorNever:
+		return ProfileNone
+	case ColorAuto, "":
+		if getenv("NO_COLOR") != "" {
+			return ProfileNone
+		}
+		switch getenv("FORCE_COLOR") {
+		case "":
+		case "0", "false":
+			return ProfileNone
+		case "2":
+			return Profile256
+		case "3":
+			return ProfileTrueColor
+		default:
+			return max(Profile16, terminalColors(getenv))
+		}
+		if getenv("TERM") == "dumb" || !IsTerminal(writer) {
+			return ProfileNone
+		}
+		return terminalColors(getenv)
+	}
+	return max(Profile16, terminalColors(getenv))
+}
+
+func terminalColors(getenv func(string) string) ColorProfile {
+	switch term := getenv("TERM"); {
+	case getenv("COLORTERM") == "truecolor" || getenv("COLORTERM") == "24bit":
+		return ProfileTrueColor
+	case strings.Contains(term, "256color"):
+		return Profile256
+	case term == "dumb":
+		return ProfileNone
+	default:
+		return Profile16
+	}
+}
+
+// ansiColors are the usual RGB values of the 16 basic colors.
+var ansiColors = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// paletteRGB is the RGB value of a color in the 256 color palette.
+func paletteRGB(index int) [3]int {
+	switch {
+	case index < 16:
+		return ansiColors[index]
+	case index < 232:
+		index -= 16
+		return [3]int{cubeLevels[index/36], cubeLevels[index/6%6], cubeLevels[index%6]}
+	default:
+		gray := 8 + (index-232)*10
+		return [3]int{gray, gray, gray}
+	}
+}
+
+func distance(a, b [3]int) int {
+	red, green, blue := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return red*red + green*green + blue*blue
+}
+
+// nearest is the closest palette index in [from, to) to rgb.
+func nearest(rgb [3]int, from, to int) int {
+	best := from
+	for index := from; index < to; index++ {
+		if distance(rgb, paletteRGB(index)) < distance(rgb, paletteRGB(best)) {
+			best = index
+		}
+	}
+	return best
+}
+
+// colorParams are the SGR parameters for rgb in profile. background selects 48 over 38.
+func colorParams(rgb [3]int, background bool, profile ColorProfile) []string {
+	switch profile {
+	case ProfileTrueColor:
+		code := "38"
+		if background {
+			code = "48"
+		}
+		return []string{code, "2", strconv.Itoa(rgb[0]), strconv.Itoa(rgb[1]), strconv.Itoa(rgb[2])}
+	case Profile256:
+		code := "38"
+		if background {
+			code = "48"
+		}
+		return []string{code, "5", strconv.Itoa(nearest(rgb, 16, 256))}
+	default:
+		index := nearest(rgb, 0, 16)
+		code := 30 + index
+		if index >= 8 {
+			code = 90 + index - 8
+		}
+		if background {
+			code += 10
+		}
+		return []string{strconv.Itoa(code)}
+	}
+}
@@ -0,0 +1,85 @@
+package compile_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectColor(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		env      map[string]string
+		expected ColorProfile
+	}{
+		{name: "not a terminal", mode: ColorAuto, expected: ProfileNone},
+		{name: "never", mode: ColorNever, env: map[string]string{"FORCE_COLOR": "1"}, expected: ProfileNone},
+		{name: "always", mode: ColorAlways, expected: Profile16},
+		{name: "always 256", mode: ColorAlways, env: map[string]string{"TERM": "xterm-256color"}, expected: Profile256},
+		{name: "always truecolor", mode: ColorAlways, env: map[string]string{"COLORTERM": "truecolor"}, expected: ProfileTrueColor},
+		{name: "force color", mode: ColorAuto, env: map[string]string{"FORCE_COLOR": "1"}, expected: Profile16},
+		{name: "force color level", mode: ColorAuto, env: map[string]string{"FORCE_COLOR": "3"}, expected: ProfileTrueColor},
+		{name: "force color off", mode: ColorAuto, env: map[string]string{"FORCE_COLOR": "0"}, expected: ProfileNone},
+		{name: "no color wins", mode: ColorAuto, env: map[string]string{"NO_COLOR": "1", "FORCE_COLOR": "1"}, expected: ProfileNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			getenv := func(key string) string { return tt.env[key] }
+			assert.Equal(t, tt.expected, DetectColor(&buf, tt.mode, getenv))
+		})
+	}
+}
+
+func TestStyleSGR(t *testing.T) {
+	orange := RGB(255, 136, 0)
+	tests := []struct {
+		name     string
+		style    Style
+		profile  ColorProfile
+		expected string
+	}{
+		{name: "no color", style: Style{Foreground: Basic(1)}, profile: ProfileNone, expected: ""},
+		{name: "empty", style: Style{}, profile: Profile16, expected: Reset},
+		{name: "basic", style: Style{Foreground: Basic(1)}, profile: Profile16, expected: Red},
+		{name: "intense background", style: Style{Background: Basic(9)}, profile: Profile16, expected: "\033[0;101m"},
+		{name: "truecolor", style: Style{Foreground: orange}, profile: ProfileTrueColor, expected: "\033[0;38;2;255;136;0m"},
+		{name: "truecolor to 256", style: Style{Foreground: orange}, profile: Profile256, expected: "\033[0;38;5;208m"},
+		{name: "truecolor to 16", style: Style{Foreground: RGB(250, 10, 10)}, profile: Profile16, expected: "\033[0;91m"},
+		{name: "background to 16", style: Style{Background: RGB(0, 0, 0)}, profile: Profile16, expected: "\033[0;40m"},
+		{name: "256 untouched", style: Style{Foreground: Palette(208)}, profile: Profile256, expected: "\033[0;38;5;208m"},
+		{name: "256 to 16", style: Style{Background: Palette(196)}, profile: Profile16, expected: "\033[0;101m"},
+		{
+			name:     "attributes",
+			style:    Style{Foreground: Basic(1), Attributes: Bold | Underline | Strike},
+			profile:  Profile16,
+			expected: "\033[0;1;4;9;31m",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.style.SGR(tt.profile))
+		})
+	}
+}
+
+func TestStyleWith(t *testing.T) {
+	underlined := Style{Foreground: Basic(1), Attributes: Underline}
+	assert.Equal(
+		t,
+		Style{Foreground: Basic(4), Background: Basic(7), Attributes: Underline | Bold},
+		underlined.With(Style{Foreground: Basic(4), Background: Basic(7), Attributes: Bold}),
+	)
+	assert.Equal(t, underlined, underlined.With(Style{}))
+}
+
+func TestRendererNoColor(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, rendererProgram.Run(&buf, RunOptions{Color: ColorNever}))
+	assert.Equal(t, "hi\nabc\b \b\b \b"+ClearANSI+"x", buf.String())
+}
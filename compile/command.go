@@ -0,0 +1,153 @@
+package compile
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Command consumes its arguments from args and returns its instructions.
+type Command func(args *CommandArgs) ([]Instruction, error)
+
+var commands = map[string]Command{
+	".":       sleepCommand,
+	"sleep":   sleepForCommand,
+	"clear":   clearCommand,
+	"wait":    waitCommand,
+	"up":      moveCommand(CursorUp),
+	"down":    moveCommand(CursorDown),
+	"left":    moveCommand(CursorLeft),
+	"right":   moveCommand(CursorRight),
+	"col":     columnCommand,
+	"goto":    gotoCommand,
+	"home":    cursorCommand(CursorHome),
+	"save":    cursorCommand(CursorSave),
+	"restore": cursorCommand(CursorRestore),
+
+	"clearline":  eraseCommand(EraseLine, false),
+	"~clearline": eraseCommand(EraseLine, true),
+	"eol":        eraseCommand(EraseEndOfLine, false),
+	"~eol":       eraseCommand(EraseEndOfLine, true),
+	"eos":        eraseCommand(EraseEndOfScreen, false),
+	"~eos":       eraseCommand(EraseEndOfScreen, true),
+	"region":     regionCommand(false),
+	"~region":    regionCommand(true),
+}
+
+// {.} sleeps for a single beat
+func sleepCommand(args *CommandArgs) ([]Instruction, error) {
+	return []Instruction{{Opcode: OpSleep, Arg: 1}}, nil
+}
+
+// {sleep EXPR} sleeps for a duration such as 1.5s or a whole number of beats.
+// The expression is the rest of the command.
+func sleepForCommand(args *CommandArgs) ([]Instruction, error) {
+	expression := args.Rest()
+	if expression == "" {
+		return nil, fmt.Errorf("expected a duration or number of beats")
+	}
+	value, err := Evaluate(expression, args.Lookup)
+	if err != nil {
+		return nil, err
+	}
+	if value.Duration {
+		if value.Number < 0 {
+			return nil, fmt.Errorf("expected a positive duration got: %s", value)
+		}
+		return []Instruction{{Opcode: OpPause, Arg: time.Duration(value.Number)}}, nil
+	}
+	if value.Number < 0 || value.Number != math.Trunc(value.Number) {
+		return nil, fmt.Errorf("expected a whole number of beats got: %s", value)
+	}
+	return []Instruction{{Opcode: OpSleep, Arg: int(value.Number)}}, nil
+}
+
+// {clear}
+func clearCommand(args *CommandArgs) ([]Instruction, error) {
+	return []Instruction{{Opcode: OpClear}}, nil
+}
+
+// {wait} or {wait 10s} blocks until a key is pressed or the optional timeout passes
+func waitCommand(args *CommandArgs) ([]Instruction, error) {
+	var timeout time.Duration
+	if word, ok := args.Peek(); ok {
+		if duration, err := time.ParseDuration(word); err == nil {
+			args.Next()
+			timeout = duration
+		}
+	}
+	return []Instruction{{Opcode: OpWait, Arg: timeout}}, nil
+}
+
+type CommandArgs struct {
+	Words []string
+	// Lookup gives the value of a $variable in an expression.
+	Lookup func(name string) (string, error)
+}
+
+func (args *CommandArgs) Peek() (string, bool) {
+	if len(args.Words) == 0 {
+		return "", false
+	}
+	return args.Words[0], true
+}
+
+func (args *CommandArgs) Next() (string, bool) {
+	word, ok := args.Peek()
+	if ok {
+		args.Words = args.Words[1:]
+	}
+	return word, ok
+}
+
+// Rest pops all of the remaining words, joined by spaces.
+func (args *CommandArgs) Rest() string {
+	rest := strings.Join(args.Words, " ")
+	args.Words = nil
+	return rest
+}
+
+// Int pops an optional positive number, returning fallback when the next word is not a number.
+// The number may be an expression without spaces such as $rows+1.
+func (args *CommandArgs) Int(fallback int) (int, error) {
+	word, ok := args.Peek()
+	if !ok || word == "" || !(unicode.IsDigit(rune(word[0])) || word[0] == '$' || word[0] == '(') {
+		return fallback, nil
+	}
+	args.Next()
+	if value, err := strconv.Atoi(word); err == nil {
+		if value < 1 {
+			return 0, fmt.Errorf(`expected a positive number got: "%s"`, word)
+		}
+		return value, nil
+	}
+	if args.Lookup == nil {
+		return 0, fmt.Errorf(`expected a positive number got: "%s"`, word)
+	}
+	value, err := Evaluate(word, args.Lookup)
+	if err != nil {
+		return 0, err
+	}
+	if value.Duration || value.Number < 1 || value.Number != math.Trunc(value.Number) {
+		return 0, fmt.Errorf(`expected a positive number got: "%s" = %s`, word, value)
+	}
+	return int(value.Number), nil
+}
+
+// splitCommand splits on whitespace with leading dots as their own words so {..clear} works.
+func splitCommand(command string) []string {
+	var words []string
+	for _, field := range strings.Fields(command) {
+		for strings.HasPrefix(field, ".") {
+			words = append(words, ".")
+			field = field[1:]
+		}
+		if field != "" {
+			words = append(words, field)
+		}
+	}
+	return words
+}
@@ -7,20 +7,91 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 )
 
 type Compile struct {
-	Input           *os.File        `arg:""`
+	Input           []string        `arg:"" name:"input" help:"Scripts to play one after another. - reads a script from stdin."`
 	Lex             bool            `short:"L" help:"Only run the lexer and print all tokens to standard out."`
 	Dump            bool            `short:"D" help:"Print all instructions to standard out then return."`
 	Optimize        bool            `negatable:"" default:"true" help:"Enable optimizations (default: enabled)"`
+	Slides          bool            `short:"S" help:"Present the input as slides separated by '---' lines. Navigate with the arrow keys."`
+	Replay          bool            `help:"Type out revisited slides again instead of showing their final state."`
+	Watch           bool            `short:"w" help:"Replay the script whenever it changes. Errors are shown until the next change."`
+	Scene           []string        `help:"Only play these scenes. Each input is a scene named after its file without the extension, or stdin."`
+	ClearBetween    bool            `help:"Clear the screen before each scene after the first."`
+	ParseOptions    ParseOptions    `embed:""`
 	OptimizeOptions OptimizeOptions `embed:""`
 	RunOptions      RunOptions      `embed:""`
+
+	// Stdin is read for the input -, defaulting to os.Stdin.
+	Stdin io.Reader `kong:"-"`
 }
 
 func (cmd *Compile) Run(ctx context.Context, stdout io.Writer) error {
+	if cmd.Watch && !cmd.Lex && !cmd.Dump {
+		return cmd.watch(ctx, stdout)
+	}
+	if err := cmd.run(ctx, stdout); !errors.Is(err, ErrQuit) {
+		return err
+	}
+	return nil
+}
+
+// run plays every scene once, returning ErrQuit if it was quit.
+func (cmd *Compile) run(ctx context.Context, stdout io.Writer) error {
+	scenes, err := cmd.scenes()
+	if err != nil {
+		return err
+	}
+	if cmd.Stdin == nil && slices.Contains(cmd.Input, StdinInput) {
+		// stdin is the script so it can't also be the keyboard
+		cmd.RunOptions.Controls = false
+	}
+
+	for i, scene := range scenes {
+		if cmd.Dump && len(scenes) > 1 {
+			if _, err := fmt.Fprintf(stdout, "scene %s:\n", scene.Name); err != nil {
+				return err
+			}
+		}
+		input, err := scene.Open(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		if err := cmd.runScene(ctx, stdout, input, i > 0 && cmd.ClearBetween); err != nil {
+			return fmt.Errorf("%s: %w", scene.Name, err)
+		}
+	}
+	return nil
+}
+
+// runScene plays a single script, first clearing the screen if clear is set.
+func (cmd *Compile) runScene(ctx context.Context, stdout io.Writer, input io.ReadCloser, clear bool) error {
+	cmd.RunOptions.Seed = cmd.ParseOptions.Seed
+	if cmd.RunOptions.Done == nil {
+		// Interrupting textly stops the program, even one that loops forever
+		cmd.RunOptions.Done = ctx.Done()
+	}
+	reader := bufio.NewReader(input)
+	header, err := ReadHeader(reader)
+	if err != nil {
+		input.Close() //nolint:errcheck
+		return err
+	}
+	parseOptions := cmd.ParseOptions.WithHeader(header)
+
+	if cmd.Slides && !cmd.Lex {
+		defer input.Close() //nolint:errcheck
+		return cmd.runSlides(ctx, stdout, reader, parseOptions)
+	}
+
 	var wg sync.WaitGroup
+	// playing is held while the program runs, which restores the terminal before returning
+	var playing sync.Mutex
 
 	errs := make(chan error)
 	tokens := make(chan Token, 10)
@@ -28,9 +99,8 @@ func (cmd *Compile) Run(ctx context.Context, stdout io.Writer) error {
 
 	// Lexer
 	wg.Go(func() {
-		defer cmd.Input.Close() //nolint:errcheck
+		defer input.Close() //nolint:errcheck
 		defer close(tokens)
-		reader := bufio.NewReader(cmd.Input)
 		if err := Lex(ctx, reader, tokens); err != nil {
 			errs <- err
 		}
@@ -44,7 +114,7 @@ func (cmd *Compile) Run(ctx context.Context, stdout io.Writer) error {
 			}
 			return
 		}
-		program, err := Parse(ctx, tokens)
+		program, err := Parse(ctx, tokens, parseOptions)
 		if err != nil {
 			errs <- err
 			return
@@ -54,6 +124,9 @@ func (cmd *Compile) Run(ctx context.Context, stdout io.Writer) error {
 		if cmd.Optimize {
 			program.Optimize(cmd.OptimizeOptions)
 		}
+		if clear {
+			program.Instructions = append([]Instruction{{Opcode: OpClear}}, program.Instructions...)
+		}
 
 		if cmd.Dump {
 			for i, instruction := range program.Instructions {
@@ -64,7 +137,15 @@ func (cmd *Compile) Run(ctx context.Context, stdout io.Writer) error {
 			return
 		}
 
-		if err := program.Run(stdout, cmd.RunOptions); err != nil {
+		err = func() error {
+			playing.Lock()
+			defer playing.Unlock()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return program.Run(stdout, cmd.RunOptions)
+		}()
+		if err != nil && !errors.Is(err, ErrStopped) {
 			errs <- err
 			return
 		}
@@ -78,6 +159,9 @@ func (cmd *Compile) Run(ctx context.Context, stdout io.Writer) error {
 
 	select {
 	case <-ctx.Done():
+		// The program stops through Done, wait for it so the keyboard is closed
+		playing.Lock()
+		defer playing.Unlock()
 		return errors.New("context closed")
 	case err := <-errs:
 		return err
@@ -86,3 +170,77 @@ func (cmd *Compile) Run(ctx context.Context, stdout io.Writer) error {
 
 	return nil
 }
+
+func (cmd *Compile) runSlides(ctx context.Context, stdout io.Writer, reader io.Reader, options ParseOptions) error {
+	slides, err := SplitSlides(reader)
+	if err != nil {
+		return fmt.Errorf("reading slides: %w", err)
+	}
+
+	deck := Deck{Replay: cmd.Replay}
+	for i, slide := range slides {
+		program, err := ParseReader(ctx, strings.NewReader(slide), options)
+		if err != nil {
+			return fmt.Errorf("slide %d: %w", i+1, err)
+		}
+		if cmd.Optimize {
+			program.Optimize(cmd.OptimizeOptions)
+		}
+		deck.Slides = append(deck.Slides, program)
+	}
+
+	if cmd.Dump {
+		for i, program := range deck.Slides {
+			if _, err := fmt.Fprintf(stdout, "slide %d:\n", i+1); err != nil {
+				return err
+			}
+			for j, instruction := range program.Instructions {
+				if _, err := fmt.Fprintf(stdout, "%3d: %s\n", j, instruction); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return deck.Play(stdout, cmd.RunOptions)
+}
+
+// StdinInput is the input that reads a script from stdin.
+const StdinInput = "-"
+
+// Scene is one of the input scripts.
+type Scene struct {
+	Name string
+	Path string
+}
+
+// Open opens the script, reading stdin for the input -.
+func (scene Scene) Open(stdin io.Reader) (io.ReadCloser, error) {
+	if scene.Path != StdinInput {
+		return os.Open(scene.Path)
+	}
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	return io.NopCloser(stdin), nil
+}
+
+// scenes are the inputs selected by --scene in order.
+func (cmd *Compile) scenes() ([]Scene, error) {
+	var scenes []Scene
+	for _, path := range cmd.Input {
+		name := "stdin"
+		if path != StdinInput {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		if len(cmd.Scene) == 0 || slices.Contains(cmd.Scene, name) {
+			scenes = append(scenes, Scene{Name: name, Path: path})
+		}
+	}
+	for _, name := range cmd.Scene {
+		if !slices.ContainsFunc(scenes, func(scene Scene) bool { return scene.Name == name }) {
+			return nil, fmt.Errorf(`unknown scene: "%s"`, name)
+		}
+	}
+	return scenes, nil
+}
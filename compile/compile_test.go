@@ -1,11 +1,18 @@
 package compile_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/ohhfishal/textly/compile"
 	"github.com/stretchr/testify/require"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValid(t *testing.T) {
@@ -33,6 +40,66 @@ func TestValid(t *testing.T) {
 			Input:  "Hello{clear}",
 			Output: "Hello" + compile.ClearANSI,
 		},
+		{
+			Input:  "a[b{clear}c]d",
+			Output: "ab" + compile.ClearANSI + "d",
+		},
+		{
+			Input:  "Hello{wait}World",
+			Output: "HelloWorld",
+		},
+		{
+			Input:  "Hello{wait 10s}{.. clear}",
+			Output: "Hello" + compile.ClearANSI,
+		},
+		{
+			Input:  "a{left}b{up 2}{down 3}{right}{col 4}{goto 2 5}{home}{save}{restore}",
+			Output: "a\033[1Db\033[2A\033[3B\033[1C\033[4G\033[2;5H\033[H\0337\0338",
+		},
+		{
+			Input:  "ab{clearline}c{eol}{eos}",
+			Output: "ab\033[2K\033[1Gc\033[K\033[J",
+		},
+		{
+			Input:  "{region 1 2 2 3}",
+			Output: "\0337\033[1;2H   \033[2;2H   \0338",
+		},
+		{
+			Input:  "@repeat(3){ab}",
+			Output: "ababab",
+		},
+		{
+			Input:  "@repeat(2){@repeat(2){a}b}@repeat(0){c}",
+			Output: "aabaab",
+		},
+		{
+			Input:  "[@repeat(2){ab}]c",
+			Output: "c",
+		},
+		{
+			Input:  "---\nvars:\n  name: Bob\n  rows: 2\n---\nHi {$name}{up $rows}{sleep $rows*2}",
+			Output: "Hi Bob\033[2A",
+		},
+		{
+			Input:  "---\nshell: enable\n---\n{$ echo '..  hi'}{~$ echo there}",
+			Output: "..  hi\nthere\n",
+		},
+		{
+			Input:  "@session{\n$ ls\nfile\n}",
+			Output: "\n$ ls\nfile\n",
+		},
+		{
+			Input:  "---\nshell: enable\nps1: '~>'\n---\n@prompt{$ echo hi\n}@session(%){$ exit}",
+			Output: "~> echo hi\nhi\n% exit",
+		},
+		{
+			Input:  "---\nshell: enable\n---\n@session{$ echo hi\nfake\n$ echo bye\n}",
+			Output: "$ echo hi\nhi\n$ echo bye\nbye\n",
+		},
+		{
+			Input:  "a\\->b",
+			Output: "a->b",
+		},
 		{
 			Input:  "@red{test}",
 			Output: compile.Red + "test" + compile.Reset,
@@ -41,8 +108,65 @@ func TestValid(t *testing.T) {
 			Input:  "@(red){test}",
 			Output: compile.Red + "test" + compile.Reset,
 		},
+		{
+			Input:  "@#ff8800{test} # comment",
+			Output: "\033[0;38;2;255;136;0mtest" + compile.Reset,
+		},
+		{
+			Input:  "@+#000{test}",
+			Output: "\033[0;48;2;0;0;0mtest" + compile.Reset,
+		},
+		{
+			Input:  "@#f80/#000{test}",
+			Output: "\033[0;38;2;255;136;0;48;2;0;0;0mtest" + compile.Reset,
+		},
+		{
+			Input:  "@red/white{test}",
+			Output: "\033[0;31;47mtest" + compile.Reset,
+		},
+		{
+			Input:  "@rgb(255, 136, 0){test}",
+			Output: "\033[0;38;2;255;136;0mtest" + compile.Reset,
+		},
+		{
+			Input:  "@rgb(255, 136, 0, 0, 0, 0){test}",
+			Output: "\033[0;38;2;255;136;0;48;2;0;0;0mtest" + compile.Reset,
+		},
+		{
+			Input:  "@color(208){test}",
+			Output: "\033[0;38;5;208mtest" + compile.Reset,
+		},
+		{
+			Input:  "@(+color(17), #fff){test}",
+			Output: "\033[0;38;2;255;255;255;48;5;17mtest" + compile.Reset,
+		},
+		{
+			Input:  "@_red{a@blue{b}c}",
+			Output: "\033[0;4;31ma\033[0;4;34mb\033[0;4;31mc" + compile.Reset,
+		},
+		{
+			Input:  "@(bold, italic){a@(dim,blink,inverse,strike){b}}",
+			Output: "\033[0;1;3ma\033[0;1;2;3;5;7;9mb\033[0;1;3m" + compile.Reset,
+		},
+		{
+			Input:  "@bold{@red{x}z@green{y}}",
+			Output: "\033[0;1m\033[0;1;31mx\033[0;1mz\033[0;1;32my\033[0;1m" + compile.Reset,
+		},
+		{
+			Input:  "@human{Hello, World!}",
+			Output: "Hello, World!",
+		},
+		{
+			Input:  "@typos(1){The quick brown fox}",
+			Output: "The quick brown fox",
+		},
+		{
+			Input:  "@(red, typos(0.5)){test}",
+			Output: compile.Red + "test" + compile.Reset,
+		},
 	}
 
+	t.Setenv("COLORTERM", "truecolor")
 	dir := t.TempDir()
 	for i, test := range tests {
 		t.Run(fmt.Sprintf("%d:%s", i, test.Input), func(t *testing.T) {
@@ -56,17 +180,14 @@ func TestValid(t *testing.T) {
 			require.Nil(err)
 			f.Close() //nolint:errcheck
 
-			f, err = os.Open(f.Name())
-			require.Nil(err)
-			defer f.Close() //nolint:errcheck
-
 			defer os.Remove(f.Name()) //nolint:errcheck
 			cmd := compile.Compile{
-				Input:    f,
+				Input:    []string{f.Name()},
 				Optimize: false,
 				RunOptions: compile.RunOptions{
 					Delay: 0,
 					Beat:  0,
+					Color: compile.ColorAlways,
 				},
 			}
 
@@ -114,3 +235,317 @@ func (terminal *MockTerminal) String() string {
 	}
 	return string(terminal.buffer[:end])
 }
+
+func TestInvalid(t *testing.T) {
+	tests := []string{
+		"{nope}",
+		"{up 0}",
+		"{up x}",
+		"{goto 1 -1}",
+		"{region 1 2}",
+		"{~region 1 2 0 1}",
+		"@nope{test}",
+		"{$missing}",
+		"{$ echo disabled}",
+		"{sleep}",
+		"{sleep 1.5}",
+		"{sleep 1s+1}",
+		"@repeat{test}",
+		"@repeat(x){test}",
+		"@forever(1){test}",
+		"@session(a, b){test}",
+		"@typos(2){test}",
+		"@rgb(1, 2){test}",
+		"@color(256){test}",
+		"@#ggg{test}",
+		"[abc",
+		"[teh->the",
+		"[a{up 1}]",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			_, err := compile.ParseReader(t.Context(), strings.NewReader(input), compile.ParseOptions{})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestEscapedArrow(t *testing.T) {
+	program, err := compile.ParseReader(t.Context(), strings.NewReader("[a\\->b]"), compile.ParseOptions{})
+	require.NoError(t, err)
+	program.Optimize(compile.OptimizeOptions{})
+
+	// The arrow is typed and deleted with the rest instead of starting a correction
+	require.Equal(t, []compile.Instruction{
+		{Opcode: compile.OpPrint, Arg: "a->b"},
+		{Opcode: compile.OpDelete, Arg: 4},
+	}, program.Instructions)
+}
+
+func TestSessionShellMarkup(t *testing.T) {
+	options := compile.ParseOptions{Shell: true}
+	_, err := compile.ParseReader(t.Context(), strings.NewReader("@session{$ ls{.}\n}"), options)
+	require.ErrorContains(t, err, "can't run a command containing commands or decorators: $ ls")
+}
+
+func TestSeed(t *testing.T) {
+	require := require.New(t)
+	parse := func(seed uint64) []compile.Instruction {
+		program, err := compile.ParseReader(
+			t.Context(),
+			strings.NewReader("@typos(0.5){The quick brown fox jumps over the lazy dog}"),
+			compile.ParseOptions{Seed: seed},
+		)
+		require.NoError(err)
+		return program.Instructions
+	}
+
+	require.Equal(parse(1), parse(1))
+	require.NotEqual(parse(1), parse(2))
+	require.Contains(parse(1), compile.Instruction{Opcode: compile.OpDelete, Arg: 1})
+}
+
+func TestBracket(t *testing.T) {
+	t.Setenv("COLORTERM", "truecolor")
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{
+			Input:  "a[b[c]d]",
+			Output: "abc\b \bd\b \b\b \b",
+		},
+		{
+			Input:  "[teh->the]",
+			Output: "teh\b \b\b \b\b \bthe",
+		},
+		{
+			Input:  "[teh~>the]",
+			Output: "teh\b \b\b \bhe",
+		},
+		{
+			Input:  "[a-b~c]",
+			Output: "a-b~c\b \b\b \b\b \b\b \b\b \b",
+		},
+		{
+			Input:  "[@red{teh}~>@red{the}]",
+			Output: compile.Red + "teh" + compile.Reset + "\b \b\b \b\b \b" + compile.Red + "the" + compile.Reset,
+		},
+		{
+			Input:  "Hel[@red{p}]lo",
+			Output: "Hel" + compile.Red + "p" + compile.Reset + "\b \blo",
+		},
+		{
+			Input:  "ab[c\nd]e",
+			Output: "abc\nd\b \b\033[1A\033[4G\b \be",
+		},
+		{
+			Input:  "@+red{ab[cd]}",
+			Output: compile.BackgroundRed + "abcd" + strings.Repeat("\b"+compile.Reset+" \b"+compile.BackgroundRed, 2) + compile.Reset,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			require := require.New(t)
+			program, err := compile.ParseReader(t.Context(), strings.NewReader(test.Input), compile.ParseOptions{})
+			require.NoError(err)
+
+			var output strings.Builder
+			require.NoError(program.Run(&output, compile.RunOptions{Color: compile.ColorAlways}))
+			require.Equal(test.Output, output.String())
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	t.Setenv("TEXTLY_TEST", "env")
+	input := "---\nvars:\n  name: header\n  other: header\n---\n{$name} {$other} {$env.TEXTLY_TEST}"
+	reader := bufio.NewReader(strings.NewReader(input))
+	header, err := compile.ReadHeader(reader)
+	require.NoError(t, err)
+
+	options := compile.ParseOptions{Vars: map[string]string{"name": "flag"}}
+	program, err := compile.ParseReader(t.Context(), reader, options.WithHeader(header))
+	require.NoError(t, err)
+
+	var output strings.Builder
+	require.NoError(t, program.Run(&output, compile.RunOptions{Output: compile.OutputPlain}))
+	require.Equal(t, "flag header env", output.String())
+}
+
+func TestReadHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		header compile.Header
+		rest   string
+	}{
+		{
+			name:   "header",
+			input:  "---\nshell: enable\n---\nhi",
+			header: compile.Header{Shell: compile.ShellEnable},
+			rest:   "hi",
+		},
+		{name: "empty header", input: "---\n---\nhi", rest: "hi"},
+		{name: "no header", input: "hi\n---\nthere", rest: "hi\n---\nthere"},
+		{name: "slide", input: "---\nhi\n---\nthere", rest: "---\nhi\n---\nthere"},
+		{name: "slide of YAML", input: "---\nagenda:\n- one\n---\n", rest: "---\nagenda:\n- one\n---\n"},
+		{name: "not closed", input: "---\nshell: enable\n", rest: "---\nshell: enable\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(test.input))
+			header, err := compile.ReadHeader(reader)
+			require.NoError(t, err)
+			require.Equal(t, test.header, header)
+
+			rest, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.Equal(t, test.rest, string(rest))
+		})
+	}
+}
+
+func TestWithHeaderShellTimeout(t *testing.T) {
+	header := compile.Header{ShellTimeout: 5 * time.Second}
+	require.Equal(t, time.Second, compile.ParseOptions{ShellTimeout: time.Second}.WithHeader(header).ShellTimeout)
+	require.Equal(t, 5*time.Second, compile.ParseOptions{}.WithHeader(header).ShellTimeout)
+	require.Equal(t, 10*time.Second, compile.ParseOptions{}.WithHeader(compile.Header{}).ShellTimeout)
+}
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]string{"pause": "500ms", "count": "3"}
+	lookup := func(name string) (string, error) {
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("unknown variable: %s", name)
+		}
+		return value, nil
+	}
+
+	tests := []struct {
+		expression string
+		expected   compile.Value
+		err        bool
+	}{
+		{expression: "1 + 2 * 3", expected: compile.Value{Number: 7}},
+		{expression: "(1 + 2) * 3", expected: compile.Value{Number: 9}},
+		{expression: "-$count / 2", expected: compile.Value{Number: -1.5}},
+		{expression: "$pause*2", expected: compile.Value{Number: float64(time.Second), Duration: true}},
+		{expression: "1s + 1.5s", expected: compile.Value{Number: float64(2500 * time.Millisecond), Duration: true}},
+		{expression: "2s / $pause", expected: compile.Value{Number: 4}},
+		{expression: "1s * 1s", err: true},
+		{expression: "1 + 1s", err: true},
+		{expression: "1 / 0", err: true},
+		{expression: "$nope", err: true},
+		{expression: "(1", err: true},
+		{expression: "1 2", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			value, err := compile.Evaluate(test.expression, lookup)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, value)
+		})
+	}
+}
+
+func TestScenes(t *testing.T) {
+	dir := t.TempDir()
+	intro := filepath.Join(dir, "intro.txt")
+	outro := filepath.Join(dir, "outro.text")
+	require.NoError(t, os.WriteFile(intro, []byte("Hello"), 0o644))
+	require.NoError(t, os.WriteFile(outro, []byte("Bye"), 0o644))
+
+	tests := []struct {
+		name   string
+		cmd    compile.Compile
+		output string
+		err    string
+	}{
+		{
+			name:   "in order",
+			cmd:    compile.Compile{Input: []string{intro, "-", outro}},
+			output: "Hello piped Bye",
+		},
+		{
+			name:   "clear between",
+			cmd:    compile.Compile{Input: []string{intro, outro}, ClearBetween: true},
+			output: "Hello" + compile.ClearANSI + "Bye",
+		},
+		{
+			name:   "select scenes",
+			cmd:    compile.Compile{Input: []string{intro, "-", outro}, Scene: []string{"outro", "stdin"}},
+			output: " piped Bye",
+		},
+		{
+			name: "unknown scene",
+			cmd:  compile.Compile{Input: []string{intro}, Scene: []string{"nope"}},
+			err:  `unknown scene: "nope"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output strings.Builder
+			test.cmd.Stdin = strings.NewReader(" piped ")
+			test.cmd.RunOptions.Color = compile.ColorNever
+			err := test.cmd.Run(t.Context(), &output)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.output, output.String())
+		})
+	}
+}
+
+func TestCompileInterrupted(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	cmd := compile.Compile{
+		Input:      []string{compile.StdinInput},
+		Stdin:      strings.NewReader("@forever{ab{sleep 1}}"),
+		RunOptions: compile.RunOptions{Beat: 10 * time.Millisecond, Color: compile.ColorNever},
+	}
+
+	// The loop runs until it is interrupted and has stopped by the time Run returns
+	var output strings.Builder
+	require.Error(cmd.Run(ctx, &output))
+	require.Greater(strings.Count(output.String(), "ab"), 1)
+}
+
+func TestGenGo(t *testing.T) {
+	require := require.New(t)
+	var output bytes.Buffer
+	cmd := compile.GenGo{
+		Input:    compile.StdinInput,
+		Package:  "intro",
+		Optimize: true,
+		Stdin:    strings.NewReader("Hi {sleep 0.5s}@bold{there}"),
+	}
+	require.NoError(cmd.Run(t.Context(), &output))
+
+	source := output.String()
+	require.Contains(source, "package intro\n")
+	require.Contains(source, "\t\"time\"\n")
+	require.Contains(source, `{Opcode: "print", Arg: "Hi "},`)
+	require.Contains(source, `{Opcode: "pause", Arg: time.Duration(500000000)},`)
+	require.Contains(source, `{Opcode: "pushStyle", Arg: compile.Style{`)
+	require.Contains(source, "func Play(ctx context.Context, stdout io.Writer, opts ...textly.Option) error {")
+
+	cmd.Stdin = strings.NewReader("Hi")
+	output.Reset()
+	require.NoError(cmd.Run(t.Context(), &output))
+	require.NotContains(output.String(), "\"time\"")
+}
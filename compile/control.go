@@ -0,0 +1,193 @@
+package compile
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrQuit is returned by [Program.Run] when playback is stopped with 'q'.
+var ErrQuit = errors.New("quit")
+
+// ErrStopped is returned by [Program.Run] when [RunOptions.Done] is closed.
+var ErrStopped = errors.New("stopped")
+
+const speedStep = 1.5
+
+// controls applies keyboard playback controls to every wait of a running program.
+// With no keys it simply sleeps on the clock.
+type controls struct {
+	clock    Clock
+	keys     <-chan Key
+	triggers <-chan os.Signal // Ends a {wait} early
+	done     <-chan struct{}  // Stops the program
+	pause    <-chan bool      // Pauses or resumes without keys
+	polling  bool             // Keys are handled before each instruction, not only while waiting
+	speed    float64
+	paused   bool
+	skipping bool // Set until the current instruction finishes
+	pressed  bool // Any other key was pressed just before this instruction, ends a {wait}
+}
+
+func newControls(keys <-chan Key, clock Clock) *controls {
+	return &controls{
+		clock:   clock,
+		keys:    keys,
+		speed:   1,
+		polling: true,
+	}
+}
+
+// next is called before each instruction.
+func (controls *controls) next() error {
+	controls.skipping = false
+	controls.pressed = false
+	if !controls.polling {
+		return nil
+	}
+	return controls.poll()
+}
+
+// poll handles any keys pressed without waiting, then holds playback while it is paused.
+func (controls *controls) poll() error {
+	for {
+		select {
+		case <-controls.done:
+			return ErrStopped
+		case paused := <-controls.pause:
+			controls.paused = paused
+		case key, ok := <-controls.keys:
+			if !ok {
+				controls.keys = nil
+				controls.paused = false
+				continue
+			}
+			if err := controls.handle(key); err != nil {
+				return err
+			}
+		default:
+			if controls.paused {
+				return controls.wait(0)
+			}
+			return nil
+		}
+	}
+}
+
+func (controls *controls) handle(key Key) error {
+	switch key {
+	case ' ':
+		controls.paused = !controls.paused
+	case '+', '=':
+		controls.speed *= speedStep
+	case '-', '_':
+		controls.speed /= speedStep
+	case KeyRight:
+		controls.skipping = true
+	case 'q', KeyInterrupt:
+		return ErrQuit
+	default:
+		controls.pressed = true
+	}
+	return nil
+}
+
+// wait sleeps for duration scaled by the current speed, handling any keys pressed meanwhile.
+func (controls *controls) wait(duration time.Duration) error {
+	if controls.skipping || (duration <= 0 && !controls.paused) {
+		return nil
+	}
+	remaining := time.Duration(float64(duration) / controls.speed)
+	if controls.keys == nil && controls.pause == nil {
+		select {
+		case <-controls.clock.After(remaining):
+			return nil
+		case <-controls.done:
+			return ErrStopped
+		}
+	}
+
+	for remaining > 0 || controls.paused {
+		start := controls.clock.Now()
+		var timer <-chan time.Time
+		if !controls.paused {
+			timer = controls.clock.After(remaining)
+		}
+
+		select {
+		case <-timer:
+			return nil
+		case <-controls.done:
+			return ErrStopped
+		case paused := <-controls.pause:
+			if !controls.paused {
+				remaining -= controls.clock.Now().Sub(start)
+			}
+			controls.paused = paused
+		case key, ok := <-controls.keys:
+			if !ok {
+				controls.keys = nil
+				controls.paused = false
+				if remaining -= controls.clock.Now().Sub(start); remaining > 0 {
+					<-controls.clock.After(remaining)
+				}
+				return nil
+			}
+			if !controls.paused {
+				remaining -= controls.clock.Now().Sub(start)
+			}
+			before := controls.speed
+			if err := controls.handle(key); err != nil {
+				return err
+			} else if controls.skipping {
+				return nil
+			}
+			remaining = time.Duration(float64(remaining) * before / controls.speed)
+		}
+	}
+	return nil
+}
+
+// waitForKey blocks until a key other than a control is pressed, a trigger fires or the
+// timeout passes. Without keys it pauses for fallback instead.
+func (controls *controls) waitForKey(timeout time.Duration, fallback time.Duration) error {
+	if controls.skipping || controls.pressed {
+		return nil
+	}
+
+	var timer <-chan time.Time
+	if controls.keys == nil && (timeout == 0 || fallback < timeout) {
+		timeout = fallback
+	}
+	if controls.keys == nil || timeout > 0 {
+		timer = controls.clock.After(timeout)
+	}
+
+	for {
+		select {
+		case <-timer:
+			return nil
+		case <-controls.triggers:
+			return nil
+		case <-controls.done:
+			return ErrStopped
+		case paused := <-controls.pause:
+			// Takes effect once the wait is over
+			controls.paused = paused
+		case key, ok := <-controls.keys:
+			if !ok {
+				controls.keys = nil
+				if timer == nil {
+					timer = controls.clock.After(fallback)
+				}
+				continue
+			}
+			if err := controls.handle(key); err != nil {
+				return err
+			}
+			if controls.skipping || controls.pressed {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package compile
+
+import "fmt"
+
+type CursorAction string
+
+const (
+	CursorUp      CursorAction = "up"
+	CursorDown    CursorAction = "down"
+	CursorLeft    CursorAction = "left"
+	CursorRight   CursorAction = "right"
+	CursorHome    CursorAction = "home"    // Top left of the screen
+	CursorColumn  CursorAction = "col"     // Column of the current line
+	CursorGoto    CursorAction = "goto"    // Row and column of the screen
+	CursorSave    CursorAction = "save"    // Remember the position
+	CursorRestore CursorAction = "restore" // Return to the saved position
+)
+
+// Cursor is a single movement of the cursor. Rows and columns start at 1.
+type Cursor struct {
+	Action CursorAction
+	Count  int // For up, down, left and right
+	Row    int // For goto
+	Column int // For col and goto
+}
+
+func (cursor Cursor) String() string {
+	switch cursor.Action {
+	case CursorUp, CursorDown, CursorLeft, CursorRight:
+		return fmt.Sprintf("%s %d", cursor.Action, cursor.Count)
+	case CursorColumn:
+		return fmt.Sprintf("%s %d", cursor.Action, cursor.Column)
+	case CursorGoto:
+		return fmt.Sprintf("%s %d %d", cursor.Action, cursor.Row, cursor.Column)
+	default:
+		return string(cursor.Action)
+	}
+}
+
+func (cursor Cursor) MarshalText() ([]byte, error) {
+	return []byte(cursor.String()), nil
+}
+
+// ANSI is the escape sequence for the movement.
+func (cursor Cursor) ANSI() string {
+	switch cursor.Action {
+	case CursorUp:
+		return fmt.Sprintf("\033[%dA", cursor.Count)
+	case CursorDown:
+		return fmt.Sprintf("\033[%dB", cursor.Count)
+	case CursorRight:
+		return fmt.Sprintf("\033[%dC", cursor.Count)
+	case CursorLeft:
+		return fmt.Sprintf("\033[%dD", cursor.Count)
+	case CursorHome:
+		return "\033[H"
+	case CursorColumn:
+		return fmt.Sprintf("\033[%dG", cursor.Column)
+	case CursorGoto:
+		return fmt.Sprintf("\033[%d;%dH", cursor.Row, cursor.Column)
+	case CursorSave:
+		return "\0337"
+	case CursorRestore:
+		return "\0338"
+	default:
+		return ""
+	}
+}
+
+// {up N}, {down N}, {left N} and {right N} move relative to the cursor, N defaults to 1
+func moveCommand(action CursorAction) Command {
+	return func(args *CommandArgs) ([]Instruction, error) {
+		count, err := args.Int(1)
+		if err != nil {
+			return nil, err
+		}
+		return []Instruction{{Opcode: OpCursor, Arg: Cursor{Action: action, Count: count}}}, nil
+	}
+}
+
+// {col N}
+func columnCommand(args *CommandArgs) ([]Instruction, error) {
+	column, err := args.Int(1)
+	if err != nil {
+		return nil, err
+	}
+	return []Instruction{{Opcode: OpCursor, Arg: Cursor{Action: CursorColumn, Column: column}}}, nil
+}
+
+// {goto ROW COL}
+func gotoCommand(args *CommandArgs) ([]Instruction, error) {
+	row, err := args.Int(1)
+	if err != nil {
+		return nil, err
+	}
+	column, err := args.Int(1)
+	if err != nil {
+		return nil, err
+	}
+	return []Instruction{{Opcode: OpCursor, Arg: Cursor{Action: CursorGoto, Row: row, Column: column}}}, nil
+}
+
+// {home}, {save} and {restore}
+func cursorCommand(action CursorAction) Command {
+	return func(args *CommandArgs) ([]Instruction, error) {
+		return []Instruction{{Opcode: OpCursor, Arg: Cursor{Action: action}}}, nil
+	}
+}
@@ -1,5 +1,169 @@
 package compile
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Decorator transforms the instructions inside of a @decorator{ ... } block.
+type Decorator func(body []Instruction) ([]Instruction, error)
+
+// decorators are looked up before colors. args is nil when there were no parentheses.
+var decorators = map[string]func(parser *parser, args []string) (Decorator, error){
+	"human":   humanDecorator,
+	"typos":   humanDecorator,
+	"repeat":  repeatDecorator,
+	"forever": foreverDecorator,
+	"session": sessionDecorator,
+	"prompt":  sessionDecorator,
+	"say":     sayDecorator,
+}
+
+// styleDecorators only set a style, so every style in a @(a, b) list can be pushed at once.
+var styleDecorators = map[string]func(args []string) (Style, error){
+	"rgb":    rgbDecorator(false),
+	"+rgb":   rgbDecorator(true),
+	"color":  paletteDecorator(false),
+	"+color": paletteDecorator(true),
+}
+
+func setStyle(style Style) Decorator {
+	return func(body []Instruction) ([]Instruction, error) {
+		instructions := []Instruction{{Opcode: OpPushStyle, Arg: style}}
+		instructions = append(instructions, body...)
+		return append(instructions, Instruction{Opcode: OpPopStyle}), nil
+	}
+}
+
+// @rgb(r, g, b) or @rgb(r, g, b, r, g, b) for a foreground and background.
+// The +rgb form sets the background.
+func rgbDecorator(background bool) func(args []string) (Style, error) {
+	return func(args []string) (Style, error) {
+		if len(args) != 3 && len(args) != 6 {
+			return Style{}, fmt.Errorf("expected 3 or 6 arguments got: %d", len(args))
+		}
+		var colors []Color
+		for i := 0; i < len(args); i += 3 {
+			var rgb [3]int
+			for j := range rgb {
+				value, err := strconv.Atoi(args[i+j])
+				if err != nil || value < 0 || value > 255 {
+					return Style{}, fmt.Errorf(`expected a number from 0 to 255 got: "%s"`, args[i+j])
+				}
+				rgb[j] = value
+			}
+			colors = append(colors, RGB(rgb[0], rgb[1], rgb[2]))
+		}
+		return colorStyle(colors, background), nil
+	}
+}
+
+// @color(208) or @color(208, 17) for a foreground and background from the 256 color palette.
+// The +color form sets the background.
+func paletteDecorator(background bool) func(args []string) (Style, error) {
+	return func(args []string) (Style, error) {
+		if len(args) != 1 && len(args) != 2 {
+			return Style{}, fmt.Errorf("expected 1 or 2 arguments got: %d", len(args))
+		}
+		var colors []Color
+		for _, arg := range args {
+			index, err := strconv.Atoi(arg)
+			if err != nil || index < 0 || index > 255 {
+				return Style{}, fmt.Errorf(`expected a number from 0 to 255 got: "%s"`, arg)
+			}
+			colors = append(colors, Palette(index))
+		}
+		return colorStyle(colors, background), nil
+	}
+}
+
+// colorStyle is the style for a foreground then background color, or only a background.
+func colorStyle(colors []Color, background bool) Style {
+	if background {
+		return Style{Background: colors[0]}
+	} else if len(colors) == 2 {
+		return Style{Foreground: colors[0], Background: colors[1]}
+	}
+	return Style{Foreground: colors[0]}
+}
+
+// parseStyleWord parses a style decorator without arguments. It is a name from styles or a
+// hex color like #ff8800, optionally followed by /background such as red/white or #ff8800/#000.
+func parseStyleWord(word string) (Style, bool) {
+	foreground, background, paired := strings.Cut(word, "/")
+	style, ok := styleWord(foreground, false)
+	if !ok {
+		return Style{}, false
+	}
+	if paired {
+		backgroundStyle, ok := styleWord(background, true)
+		if !ok {
+			return Style{}, false
+		}
+		style = style.With(backgroundStyle)
+	}
+	return style, true
+}
+
+func styleWord(word string, background bool) (Style, bool) {
+	if name, ok := strings.CutPrefix(word, "+"); ok {
+		word, background = name, true
+	}
+
+	if hex, ok := strings.CutPrefix(word, "#"); ok {
+		rgb, ok := parseHex(hex)
+		if !ok {
+			return Style{}, false
+		}
+		return colorStyle([]Color{RGB(rgb[0], rgb[1], rgb[2])}, background), true
+	}
+
+	if background {
+		word = "+" + word
+	}
+	style, ok := styles[word]
+	return style, ok
+}
+
+// parseHex parses rrggbb or rgb.
+func parseHex(hex string) ([3]int, bool) {
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	var rgb [3]int
+	if len(hex) != 6 {
+		return rgb, false
+	}
+	for i := range rgb {
+		value, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return rgb, false
+		}
+		rgb[i] = int(value)
+	}
+	return rgb, true
+}
+
+// @human or @typos(rate) randomly adds typos that are then corrected.
+func humanDecorator(parser *parser, args []string) (Decorator, error) {
+	rate := defaultTypoRate
+	switch len(args) {
+	case 0:
+	case 1:
+		var err error
+		rate, err = strconv.ParseFloat(args[0], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf(`expected a rate between 0 and 1 got: "%s"`, args[0])
+		}
+	default:
+		return nil, fmt.Errorf("expected at most 1 argument got: %d", len(args))
+	}
+	return func(body []Instruction) ([]Instruction, error) {
+		return addTypos(body, rate, parser.rand), nil
+	}, nil
+}
+
 const (
 	Reset = "\033[0m"
 
@@ -40,41 +204,35 @@ const (
 	IntenseWhite  = "\033[0;97m"
 )
 
-var colorMap = map[string]string{
-	"red":    Red,
-	"blue":   Blue,
-	"black":  Black,
-	"green":  Green,
-	"yellow": Yellow,
-	"purple": Purple,
-	"cyan":   Cyan,
-	"white":  White,
-	"grey":   IntenseBlack,
-
-	"_red":    UnderlinedRed,
-	"_blue":   UnderlinedBlue,
-	"_black":  UnderlinedBlack,
-	"_green":  UnderlinedGreen,
-	"_yellow": UnderlinedYellow,
-	"_purple": UnderlinedPurple,
-	"_cyan":   UnderlinedCyan,
-	"_white":  UnderlinedWhite,
-
-	"+red":    BackgroundRed,
-	"+blue":   BackgroundBlue,
-	"+black":  BackgroundBlack,
-	"+green":  BackgroundGreen,
-	"+yellow": BackgroundYellow,
-	"+purple": BackgroundPurple,
-	"+cyan":   BackgroundCyan,
-	"+white":  BackgroundWhite,
-
-	"!red":    IntenseRed,
-	"!blue":   IntenseBlue,
-	"!black":  IntenseBlack,
-	"!green":  IntenseGreen,
-	"!yellow": IntenseYellow,
-	"!purple": IntensePurple,
-	"!cyan":   IntenseCyan,
-	"!white":  IntenseWhite,
+// styles are the decorators that only set a style. Colors are prefixed with _ for underlined,
+// + for background and ! for intense.
+var styles = func() map[string]Style {
+	styles := map[string]Style{
+		"grey":      {Foreground: Basic(8)},
+		"bold":      {Attributes: Bold},
+		"dim":       {Attributes: Dim},
+		"italic":    {Attributes: Italic},
+		"underline": {Attributes: Underline},
+		"blink":     {Attributes: Blink},
+		"inverse":   {Attributes: Inverse},
+		"strike":    {Attributes: Strike},
+	}
+	for name, index := range basicColors {
+		styles[name] = Style{Foreground: Basic(index)}
+		styles["_"+name] = Style{Foreground: Basic(index), Attributes: Underline}
+		styles["+"+name] = Style{Background: Basic(index)}
+		styles["!"+name] = Style{Foreground: Basic(index + 8)}
+	}
+	return styles
+}()
+
+var basicColors = map[string]int{
+	"black":  0,
+	"red":    1,
+	"green":  2,
+	"yellow": 3,
+	"blue":   4,
+	"purple": 5,
+	"cyan":   6,
+	"white":  7,
 }
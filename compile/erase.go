@@ -0,0 +1,90 @@
+package compile
+
+import (
+	"fmt"
+	"strings"
+)
+
+type EraseTarget string
+
+const (
+	EraseLine        EraseTarget = "clearline" // The whole current line, leaving the cursor at its start
+	EraseEndOfLine   EraseTarget = "eol"       // From the cursor to the end of the line
+	EraseEndOfScreen EraseTarget = "eos"       // From the cursor to the end of the screen
+	EraseRegion      EraseTarget = "region"    // A rectangle of the screen, the cursor does not move
+)
+
+// Erase blanks part of the screen. Rows and columns start at 1.
+type Erase struct {
+	Target EraseTarget
+	// Animated erases a character at a time instead of all at once.
+	Animated bool
+	Row      int // For region
+	Column   int // For region
+	Height   int // For region
+	Width    int // For region
+}
+
+func (erase Erase) String() string {
+	name := string(erase.Target)
+	if erase.Animated {
+		name = "~" + name
+	}
+	if erase.Target == EraseRegion {
+		return fmt.Sprintf("%s %d %d %d %d", name, erase.Row, erase.Column, erase.Height, erase.Width)
+	}
+	return name
+}
+
+func (erase Erase) MarshalText() ([]byte, error) {
+	return []byte(erase.String()), nil
+}
+
+// ANSI is the escape sequence that instantly erases.
+func (erase Erase) ANSI() string {
+	switch erase.Target {
+	case EraseLine:
+		return "\033[2K\033[1G"
+	case EraseEndOfLine:
+		return "\033[K"
+	case EraseEndOfScreen:
+		return "\033[J"
+	case EraseRegion:
+		var builder strings.Builder
+		builder.WriteString(Cursor{Action: CursorSave}.ANSI())
+		for line := range erase.Height {
+			builder.WriteString(Cursor{Action: CursorGoto, Row: erase.Row + line, Column: erase.Column}.ANSI())
+			builder.WriteString(strings.Repeat(" ", erase.Width))
+		}
+		builder.WriteString(Cursor{Action: CursorRestore}.ANSI())
+		return builder.String()
+	default:
+		return ""
+	}
+}
+
+// {clearline}, {eol} and {eos}. Prefixed with ~ they are animated.
+func eraseCommand(target EraseTarget, animated bool) Command {
+	return func(args *CommandArgs) ([]Instruction, error) {
+		return []Instruction{{Opcode: OpErase, Arg: Erase{Target: target, Animated: animated}}}, nil
+	}
+}
+
+// {region ROW COL HEIGHT WIDTH} or {~region ROW COL HEIGHT WIDTH}
+func regionCommand(animated bool) Command {
+	return func(args *CommandArgs) ([]Instruction, error) {
+		erase := Erase{Target: EraseRegion, Animated: animated}
+		for _, value := range []*int{&erase.Row, &erase.Column, &erase.Height, &erase.Width} {
+			if _, ok := args.Peek(); !ok {
+				return nil, fmt.Errorf("expected ROW COL HEIGHT WIDTH")
+			}
+			var err error
+			if *value, err = args.Int(0); err != nil {
+				return nil, err
+			} else if *value == 0 {
+				return nil, fmt.Errorf("expected ROW COL HEIGHT WIDTH")
+			}
+		}
+		return []Instruction{{Opcode: OpErase, Arg: erase}}, nil
+	}
+}
@@ -0,0 +1,136 @@
+package compile
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+const ShellEnable = "enable"
+
+// defaultShellTimeout is used when neither --shell-timeout nor the header set one.
+const defaultShellTimeout = 10 * time.Second
+
+// Exec is a shell command whose output is shown while the program plays.
+type Exec struct {
+	Command string
+	// Typed types the output instead of showing it all at once.
+	Typed   bool
+	Dir     string
+	Timeout time.Duration
+}
+
+func (exec Exec) String() string {
+	if exec.Typed {
+		return "~$ " + exec.Command
+	}
+	return "$ " + exec.Command
+}
+
+func (exec Exec) MarshalText() ([]byte, error) {
+	return []byte(exec.String()), nil
+}
+
+// {$ command} shows the output of command, {~$ command} types it. The command is the
+// rest of the braces and only runs when shell commands are enabled.
+func (parser *parser) exec(command string, typed bool) (Instruction, error) {
+	if !parser.options.Shell {
+		return Instruction{}, fmt.Errorf("shell commands are disabled, add 'shell: %s' to the header or pass --shell", ShellEnable)
+	} else if command == "" {
+		return Instruction{}, errors.New("expected a shell command")
+	}
+	return Instruction{
+		Opcode: OpExec,
+		Arg: Exec{
+			Command: command,
+			Typed:   typed,
+			Dir:     parser.options.ShellDir,
+			Timeout: parser.options.ShellTimeout,
+		},
+	}, nil
+}
+
+// exec runs the command with sh and streams its stdout. A failing command is shown like any
+// other, only failing to start or timing out stops the program. Stopping the program
+// kills the command.
+func (player *player) exec(command Exec) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if command.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, command.Timeout)
+		defer cancelTimeout()
+	}
+	go func() {
+		select {
+		case <-player.controls.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	reader, writer := io.Pipe()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command.Command)
+	cmd.Dir = command.Dir
+	cmd.Stdout = writer
+	// Stop reading once it is killed even if its children still hold on to stdout
+	cmd.WaitDelay = 100 * time.Millisecond
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		writer.Close() //nolint:errcheck
+		done <- err
+	}()
+
+	if err := player.show(bufio.NewReader(reader), command.Typed); err != nil {
+		cancel()
+		reader.Close() //nolint:errcheck
+		<-done
+		return err
+	}
+
+	err := <-done
+	select {
+	case <-player.controls.done:
+		return ErrStopped
+	default:
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: timed out after %s", command, command.Timeout)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}
+
+// show draws everything from reader, typing it when typed is set.
+func (player *player) show(reader *bufio.Reader, typed bool) error {
+	for {
+		char, _, err := reader.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch {
+		case char == '\r':
+		case typed:
+			err = player.print(char, 0)
+		default:
+			err = player.draw(char)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
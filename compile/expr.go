@@ -0,0 +1,209 @@
+package compile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Value is the result of an expression, a plain number or a duration.
+type Value struct {
+	Number float64
+	// Duration is set when Number is a number of nanoseconds.
+	Duration bool
+}
+
+func (value Value) String() string {
+	if value.Duration {
+		return time.Duration(value.Number).String()
+	}
+	return strconv.FormatFloat(value.Number, 'f', -1, 64)
+}
+
+// variable looks up $name, or the environment variable X for $env.X.
+func (parser *parser) variable(name string) (string, error) {
+	if key, ok := strings.CutPrefix(name, "env."); ok {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return "", fmt.Errorf(`environment variable not set: "%s"`, key)
+		}
+		return value, nil
+	}
+	value, ok := parser.options.Vars[name]
+	if !ok {
+		return "", fmt.Errorf(`unknown variable: "%s"`, name)
+	}
+	return value, nil
+}
+
+// Evaluate evaluates arithmetic such as ($pause + 1s) * 2. Numbers may have a duration
+// unit, and lookup gives the value of each $variable.
+func Evaluate(expression string, lookup func(name string) (string, error)) (Value, error) {
+	evaluator := evaluator{
+		text:   []rune(expression),
+		lookup: lookup,
+	}
+	value, err := evaluator.sum()
+	if err != nil {
+		return Value{}, err
+	}
+	evaluator.skipSpace()
+	if evaluator.pos < len(evaluator.text) {
+		return Value{}, fmt.Errorf(`unexpected "%s" in: "%s"`, string(evaluator.text[evaluator.pos:]), expression)
+	}
+	return value, nil
+}
+
+type evaluator struct {
+	text   []rune
+	pos    int
+	lookup func(name string) (string, error)
+}
+
+func (evaluator *evaluator) skipSpace() {
+	for evaluator.pos < len(evaluator.text) && unicode.IsSpace(evaluator.text[evaluator.pos]) {
+		evaluator.pos++
+	}
+}
+
+// next skips whitespace and returns the next character or 0 at the end.
+func (evaluator *evaluator) next() rune {
+	evaluator.skipSpace()
+	if evaluator.pos >= len(evaluator.text) {
+		return 0
+	}
+	return evaluator.text[evaluator.pos]
+}
+
+// sum is product (("+" | "-") product)*
+func (evaluator *evaluator) sum() (Value, error) {
+	left, err := evaluator.product()
+	if err != nil {
+		return Value{}, err
+	}
+	for {
+		operator := evaluator.next()
+		if operator != '+' && operator != '-' {
+			return left, nil
+		}
+		evaluator.pos++
+		right, err := evaluator.product()
+		if err != nil {
+			return Value{}, err
+		}
+		if left.Duration != right.Duration {
+			return Value{}, fmt.Errorf("can not mix numbers and durations: %s %c %s", left, operator, right)
+		}
+		if operator == '+' {
+			left.Number += right.Number
+		} else {
+			left.Number -= right.Number
+		}
+	}
+}
+
+// product is unary (("*" | "/") unary)*
+func (evaluator *evaluator) product() (Value, error) {
+	left, err := evaluator.unary()
+	if err != nil {
+		return Value{}, err
+	}
+	for {
+		operator := evaluator.next()
+		if operator != '*' && operator != '/' {
+			return left, nil
+		}
+		evaluator.pos++
+		right, err := evaluator.unary()
+		if err != nil {
+			return Value{}, err
+		}
+
+		switch {
+		case operator == '*' && left.Duration && right.Duration:
+			return Value{}, fmt.Errorf("can not multiply durations: %s * %s", left, right)
+		case operator == '*':
+			left = Value{Number: left.Number * right.Number, Duration: left.Duration || right.Duration}
+		case right.Number == 0:
+			return Value{}, errors.New("division by zero")
+		case right.Duration && !left.Duration:
+			return Value{}, fmt.Errorf("can not divide a number by a duration: %s / %s", left, right)
+		default:
+			left = Value{Number: left.Number / right.Number, Duration: left.Duration && !right.Duration}
+		}
+	}
+}
+
+// unary is "-" unary | primary
+func (evaluator *evaluator) unary() (Value, error) {
+	if evaluator.next() != '-' {
+		return evaluator.primary()
+	}
+	evaluator.pos++
+	value, err := evaluator.unary()
+	value.Number = -value.Number
+	return value, err
+}
+
+// primary is a number, a duration, a $variable or "(" sum ")"
+func (evaluator *evaluator) primary() (Value, error) {
+	switch char := evaluator.next(); {
+	case char == '(':
+		evaluator.pos++
+		value, err := evaluator.sum()
+		if err != nil {
+			return Value{}, err
+		}
+		if evaluator.next() != ')' {
+			return Value{}, errors.New(`expected ")"`)
+		}
+		evaluator.pos++
+		return value, nil
+	case char == '$':
+		evaluator.pos++
+		name := evaluator.scan(func(char rune) bool {
+			return unicode.IsLetter(char) || unicode.IsDigit(char) || char == '_' || char == '.'
+		})
+		text, err := evaluator.lookup(name)
+		if err != nil {
+			return Value{}, err
+		}
+		value, err := parseValue(strings.TrimSpace(text))
+		if err != nil {
+			return Value{}, fmt.Errorf("$%s: %w", name, err)
+		}
+		return value, nil
+	case unicode.IsDigit(char) || char == '.':
+		return parseValue(evaluator.scan(func(char rune) bool {
+			return unicode.IsDigit(char) || unicode.IsLetter(char) || char == '.'
+		}))
+	case char == 0:
+		return Value{}, errors.New("unexpected end of expression")
+	default:
+		return Value{}, fmt.Errorf(`unexpected "%c"`, char)
+	}
+}
+
+// scan consumes characters while match is true.
+func (evaluator *evaluator) scan(match func(char rune) bool) string {
+	start := evaluator.pos
+	for evaluator.pos < len(evaluator.text) && match(evaluator.text[evaluator.pos]) {
+		evaluator.pos++
+	}
+	return string(evaluator.text[start:evaluator.pos])
+}
+
+// parseValue parses a number like 1.5 or a duration like 1.5s.
+func parseValue(text string) (Value, error) {
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return Value{Number: number}, nil
+	}
+	if duration, err := time.ParseDuration(text); err == nil {
+		return Value{Number: float64(duration), Duration: true}, nil
+	}
+	return Value{}, fmt.Errorf(`expected a number or duration got: "%s"`, text)
+}
@@ -0,0 +1,135 @@
+package compile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// GenGo writes a Go file holding a compiled script so it can be played without the script.
+type GenGo struct {
+	Input           string          `arg:"" name:"input" help:"Script to compile. - reads it from stdin."`
+	Package         string          `name:"pkg" default:"main" help:"Package of the generated file."`
+	Output          string          `short:"o" help:"File to write instead of standard out."`
+	Optimize        bool            `negatable:"" default:"true" help:"Enable optimizations (default: enabled)"`
+	ParseOptions    ParseOptions    `embed:""`
+	OptimizeOptions OptimizeOptions `embed:""`
+
+	// Stdin is read for the input -, defaulting to os.Stdin.
+	Stdin io.Reader `kong:"-"`
+}
+
+func (cmd *GenGo) Run(ctx context.Context, stdout io.Writer) error {
+	input, err := Scene{Path: cmd.Input}.Open(cmd.Stdin)
+	if err != nil {
+		return err
+	}
+	defer input.Close() //nolint:errcheck
+
+	program, err := compileScript(ctx, input, cmd.ParseOptions, cmd.Optimize, cmd.OptimizeOptions)
+	if err != nil {
+		return err
+	}
+	source, err := GenerateGo(*program, cmd.Package, filepath.Base(cmd.Input))
+	if err != nil {
+		return err
+	}
+
+	if cmd.Output != "" {
+		return os.WriteFile(cmd.Output, source, 0o644)
+	}
+	_, err = stdout.Write(source)
+	return err
+}
+
+// compileScript reads a whole script, including its header, into a program.
+func compileScript(ctx context.Context, reader io.Reader, options ParseOptions, optimize bool, optimizeOptions OptimizeOptions) (*Program, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := ReadHeader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	program, err := ParseReader(ctx, buffered, options.WithHeader(header))
+	if err != nil {
+		return nil, err
+	}
+	if optimize {
+		program.Optimize(optimizeOptions)
+	}
+	return program, nil
+}
+
+// GenerateGo returns the formatted source of a Go file in pkg with program as the
+// variable Program and a Play function. source names the script in the header comment.
+func GenerateGo(program Program, pkg string, source string) ([]byte, error) {
+	var instructions strings.Builder
+	usesTime := false
+	for _, instruction := range program.Instructions {
+		arg, err := goLiteral(instruction.Arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", instruction, err)
+		}
+		if argType := reflect.TypeOf(instruction.Arg); argType != nil && argType.PkgPath() == "time" {
+			usesTime = true
+		}
+		fmt.Fprintf(&instructions, "\t{Opcode: %q, Arg: %s},\n", instruction.Opcode, arg)
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by textly gen-go from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&file, "package %s\n\nimport (\n\t\"context\"\n\t\"io\"\n", pkg)
+	if usesTime {
+		file.WriteString("\t\"time\"\n")
+	}
+	file.WriteString(`
+	"github.com/ohhfishal/textly"
+	"github.com/ohhfishal/textly/compile"
+)
+
+// Program is the compiled script.
+var Program = &textly.Program{Instructions: []compile.Instruction{
+`)
+	file.WriteString(instructions.String())
+	file.WriteString(`}}
+
+// Play plays the script to stdout, returning once it is done or ctx is cancelled.
+func Play(ctx context.Context, stdout io.Writer, opts ...textly.Option) error {
+	player := textly.NewPlayer(Program, stdout, opts...)
+	if err := player.Start(ctx); err != nil {
+		return err
+	}
+	if err := player.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+`)
+	return format.Source(file.Bytes())
+}
+
+// goLiteral is arg as a Go expression of the same type.
+func goLiteral(arg any) (string, error) {
+	if arg == nil {
+		return "nil", nil
+	}
+	value := reflect.ValueOf(arg)
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return "", fmt.Errorf("can't generate an argument of type %T", arg)
+	case reflect.Struct:
+		// %#v already names the type
+		return fmt.Sprintf("%#v", arg), nil
+	}
+	if value.Type().PkgPath() == "" {
+		return fmt.Sprintf("%#v", arg), nil
+	}
+	// Named types such as time.Duration would otherwise become plain numbers
+	return fmt.Sprintf("%T(%#v)", arg, arg), nil
+}
@@ -0,0 +1,99 @@
+package compile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Header is the YAML between "---" lines at the very top of a script.
+type Header struct {
+	// Vars are the defaults for {$name}, overridden by --var.
+	Vars map[string]string `yaml:"vars"`
+	// Shell is "enable" to allow {$ command}.
+	Shell        string        `yaml:"shell"`
+	ShellDir     string        `yaml:"shell-dir"`
+	ShellTimeout time.Duration `yaml:"shell-timeout"`
+	PS1          string        `yaml:"ps1"`
+}
+
+// ReadHeader consumes the header from the start of reader, if there is one. The
+// lines after an opening "---" are only a header when they are closed by another
+// "---" and are YAML that sets nothing but the fields of [Header]. Anything else,
+// such as a slide deck opening with a separator, is left unread. The header must
+// fit in the buffer of reader.
+func ReadHeader(reader *bufio.Reader) (Header, error) {
+	var header Header
+	first, err := reader.Peek(len(SlideSeparator) + 1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return header, err
+	}
+	if strings.TrimRight(string(first), "\r\n") != SlideSeparator || !bytes.ContainsAny(first, "\r\n") {
+		return header, nil
+	}
+
+	// Peek stops at the end of the input or a full buffer, both of which are fine
+	peeked, err := reader.Peek(reader.Size())
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return header, err
+	}
+	_, rest, _ := bytes.Cut(peeked, []byte("\n"))
+	size := len(peeked) - len(rest)
+	var body []byte
+	for closed := false; !closed; {
+		line, after, found := bytes.Cut(rest, []byte("\n"))
+		if !found && len(peeked) == reader.Size() {
+			// The last line may continue past the buffer
+			return header, nil
+		}
+		if string(bytes.TrimSpace(line)) == SlideSeparator {
+			closed = true
+		} else if !found {
+			return header, nil
+		} else {
+			body = append(body, line...)
+			body = append(body, '\n')
+		}
+		size += len(rest) - len(after)
+		rest = after
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&header); err != nil && !errors.Is(err, io.EOF) {
+		return Header{}, nil
+	}
+	_, err = reader.Discard(size)
+	return header, err
+}
+
+// WithHeader fills in options from header without overriding flags.
+func (options ParseOptions) WithHeader(header Header) ParseOptions {
+	vars := maps.Clone(header.Vars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	maps.Copy(vars, options.Vars)
+	options.Vars = vars
+
+	options.Shell = options.Shell || header.Shell == ShellEnable
+	if options.ShellDir == "" {
+		options.ShellDir = header.ShellDir
+	}
+	if options.PS1 == "" {
+		options.PS1 = header.PS1
+	}
+	if options.ShellTimeout == 0 {
+		options.ShellTimeout = header.ShellTimeout
+	}
+	if options.ShellTimeout == 0 {
+		options.ShellTimeout = defaultShellTimeout
+	}
+	return options
+}
@@ -0,0 +1,112 @@
+package compile
+
+import (
+	"math/rand/v2"
+	"unicode"
+)
+
+const defaultTypoRate = 0.05
+
+var qwertyRows = []string{
+	"1234567890-=",
+	"qwertyuiop[]",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// adjacentKeys maps each key to the keys touching it on a QWERTY keyboard.
+var adjacentKeys = func() map[rune][]rune {
+	adjacent := map[rune][]rune{}
+	for row, keys := range qwertyRows {
+		for col, key := range keys {
+			// Rows are staggered so the row above shares col and col+1, the row below col-1 and col
+			neighbors := []struct{ row, col int }{
+				{row, col - 1}, {row, col + 1},
+				{row - 1, col}, {row - 1, col + 1},
+				{row + 1, col - 1}, {row + 1, col},
+			}
+			for _, neighbor := range neighbors {
+				if neighbor.row < 0 || neighbor.row >= len(qwertyRows) {
+					continue
+				}
+				other := []rune(qwertyRows[neighbor.row])
+				if neighbor.col < 0 || neighbor.col >= len(other) {
+					continue
+				}
+				adjacent[key] = append(adjacent[key], other[neighbor.col])
+			}
+		}
+	}
+	return adjacent
+}()
+
+// addTypos inserts mistakes into runs of printed text, each followed by its correction.
+func addTypos(body []Instruction, rate float64, rng *rand.Rand) []Instruction {
+	var instructions []Instruction
+	var text []rune
+	flush := func() {
+		instructions = append(instructions, typeWithTypos(text, rate, rng)...)
+		text = nil
+	}
+
+	for _, instruction := range body {
+		if instruction.Opcode == OpPrint {
+			text = append(text, []rune(instruction.Arg.(string))...)
+			continue
+		}
+		flush()
+		instructions = append(instructions, instruction)
+	}
+	flush()
+	return instructions
+}
+
+func typeWithTypos(text []rune, rate float64, rng *rand.Rand) []Instruction {
+	var instructions []Instruction
+	typeRunes := func(chars ...rune) {
+		for _, char := range chars {
+			instructions = append(instructions, Instruction{Opcode: OpPrint, Arg: string(char)})
+		}
+	}
+	fix := func(count int) {
+		instructions = append(instructions, Instruction{Opcode: OpDelete, Arg: count})
+	}
+
+	for i := 0; i < len(text); i++ {
+		char := text[i]
+		neighbors := adjacentKeys[unicode.ToLower(char)]
+		if len(neighbors) == 0 || rng.Float64() >= rate {
+			typeRunes(char)
+			continue
+		}
+
+		switch rng.IntN(3) {
+		case 0:
+			// Hit a neighboring key
+			wrong := neighbors[rng.IntN(len(neighbors))]
+			if unicode.IsUpper(char) {
+				wrong = unicode.ToUpper(wrong)
+			}
+			typeRunes(wrong)
+			fix(1)
+			typeRunes(char)
+		case 1:
+			// Doubled letter
+			typeRunes(char, char)
+			fix(1)
+		case 2:
+			// Swapped with the next letter
+			if i+1 >= len(text) || len(adjacentKeys[unicode.ToLower(text[i+1])]) == 0 {
+				typeRunes(char, char)
+				fix(1)
+				continue
+			}
+			next := text[i+1]
+			typeRunes(next, char)
+			fix(2)
+			typeRunes(char, next)
+			i++
+		}
+	}
+	return instructions
+}
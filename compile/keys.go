@@ -0,0 +1,144 @@
+package compile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Key is a single keypress. Printable keys are their rune, special keys are negative.
+type Key rune
+
+const (
+	KeyUp Key = -(iota + 1)
+	KeyDown
+	KeyRight
+	KeyLeft
+)
+
+const (
+	KeyEnter     Key = '\r'
+	KeyEscape    Key = '\033'
+	KeyInterrupt Key = 0x03 // Ctrl-C while in raw mode
+)
+
+func (key Key) String() string {
+	switch key {
+	case KeyUp:
+		return "UP"
+	case KeyDown:
+		return "DOWN"
+	case KeyRight:
+		return "RIGHT"
+	case KeyLeft:
+		return "LEFT"
+	case KeyEnter:
+		return "ENTER"
+	case KeyEscape:
+		return "ESC"
+	default:
+		return string(rune(key))
+	}
+}
+
+var arrowKeys = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+}
+
+// DecodeKeys splits a chunk read from a raw terminal into keys.
+func DecodeKeys(p []byte) []Key {
+	var keys []Key
+	for len(p) > 0 {
+		if bytes.HasPrefix(p, []byte("\033[")) && len(p) >= 3 {
+			if key, ok := arrowKeys[p[2]]; ok {
+				keys = append(keys, key)
+				p = p[3:]
+				continue
+			}
+		}
+		char, size := utf8.DecodeRune(p)
+		if char == '\n' {
+			char = '\r'
+		}
+		keys = append(keys, Key(char))
+		p = p[size:]
+	}
+	return keys
+}
+
+// ReadKeys decodes keys from reader until it fails, then closes the channel.
+func ReadKeys(reader io.Reader, keys chan<- Key) error {
+	defer close(keys)
+	buffer := make([]byte, 64)
+	for {
+		n, err := reader.Read(buffer)
+		for _, key := range DecodeKeys(buffer[:n]) {
+			keys <- key
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Keyboard puts stdin into raw mode and starts reading keys from it.
+type Keyboard struct {
+	Keys  <-chan Key
+	state *term.State
+	// stop ends reading keys.
+	stop func() error
+}
+
+func IsTerminal(writer io.Writer) bool {
+	if raw, ok := writer.(RawWriter); ok {
+		writer = raw.Writer
+	}
+	file, ok := writer.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+// keyboardAvailable reports whether keys can be read from stdin while playing to
+// stdout. Both have to be terminals, so piped input plays without controls.
+func keyboardAvailable(stdout io.Writer) bool {
+	return IsTerminal(stdout) && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func OpenKeyboard() (*Keyboard, error) {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	keys, stop, err := readStdinKeys()
+	if err != nil {
+		term.Restore(int(os.Stdin.Fd()), state) //nolint:errcheck
+		return nil, err
+	}
+	return &Keyboard{
+		Keys:  keys,
+		state: state,
+		stop:  stop,
+	}, nil
+}
+
+func (keyboard *Keyboard) Close() error {
+	return errors.Join(keyboard.stop(), term.Restore(int(os.Stdin.Fd()), keyboard.state))
+}
+
+// RawWriter restores the carriage returns raw mode stops the terminal from adding.
+type RawWriter struct {
+	io.Writer
+}
+
+func (writer RawWriter) Write(p []byte) (int, error) {
+	if _, err := writer.Writer.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
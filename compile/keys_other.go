@@ -0,0 +1,25 @@
+//go:build !unix
+
+package compile
+
+import (
+	"os"
+	"sync"
+)
+
+// stdinKeys is the one reader of stdin, shared by every keyboard since a read in
+// progress can't be cancelled here.
+var stdinKeys = sync.OnceValue(func() <-chan Key {
+	keys := make(chan Key, 10)
+	go ReadKeys(os.Stdin, keys) //nolint:errcheck
+	return keys
+})
+
+// readStdinKeys returns the shared keys, dropping any pressed while no keyboard was open.
+func readStdinKeys() (<-chan Key, func() error, error) {
+	keys := stdinKeys()
+	for len(keys) > 0 {
+		<-keys
+	}
+	return keys, func() error { return nil }, nil
+}
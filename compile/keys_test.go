@@ -0,0 +1,29 @@
+package compile_test
+
+import (
+	"testing"
+
+	. "github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Key
+	}{
+		{input: " ", expected: []Key{' '}},
+		{input: "\033[C", expected: []Key{KeyRight}},
+		{input: "\033[D\033[A", expected: []Key{KeyLeft, KeyUp}},
+		{input: "+q", expected: []Key{'+', 'q'}},
+		{input: "\033", expected: []Key{KeyEscape}},
+		{input: "\n", expected: []Key{KeyEnter}},
+		{input: "", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DecodeKeys([]byte(tt.input)))
+		})
+	}
+}
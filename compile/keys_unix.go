@@ -0,0 +1,30 @@
+//go:build unix
+
+package compile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// readStdinKeys reads keys from a non-blocking copy of stdin until stop is called.
+// Closing the copy ends a read in progress, so nothing is taken from stdin once the
+// keyboard is closed.
+func readStdinKeys() (<-chan Key, func() error, error) {
+	fd, err := syscall.Dup(syscall.Stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd) //nolint:errcheck
+		return nil, nil, err
+	}
+	stdin := os.NewFile(uintptr(fd), "stdin")
+	keys := make(chan Key, 10)
+	go ReadKeys(stdin, keys) //nolint:errcheck
+	return keys, func() error {
+		// The copy shares its flags with stdin, which the rest of the process expects to block
+		return errors.Join(stdin.Close(), syscall.SetNonblock(syscall.Stdin, false))
+	}, nil
+}
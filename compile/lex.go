@@ -26,6 +26,8 @@ type Token struct {
 	Line   int
 	Column int
 	Value  string
+	// Escaped is set on a character written after a backslash.
+	Escaped bool
 }
 
 func (tokenType TokenType) String() string {
@@ -100,6 +102,7 @@ func Lex(ctx context.Context, file *bufio.Reader, tokens chan<- Token) error {
 	var line, column int
 	var escaped bool
 	var comment bool
+	var decorator bool // Between an @ and its {, where # starts a color instead of a comment
 	for {
 		char, err := reader.Pop()
 		if errors.Is(err, io.EOF) {
@@ -128,20 +131,23 @@ func Lex(ctx context.Context, file *bufio.Reader, tokens chan<- Token) error {
 			}
 			escaped = false
 			comment = false
+			decorator = false
 			line++
 			column = -1
 		case !escaped && char == '@':
+			decorator = true
 			tokens <- Token{
 				Type:   TokenDecorator,
 				Value:  "@",
 				Line:   line,
 				Column: column,
 			}
-		case !escaped && char == '#':
+		case !escaped && !decorator && char == '#':
 			comment = true
 		case !escaped && char == '\\':
 			escaped = true
 		case !escaped && char == '{':
+			decorator = false
 			tokens <- Token{
 				Type:   TokenCommandStart,
 				Value:  "{",
@@ -171,10 +177,11 @@ func Lex(ctx context.Context, file *bufio.Reader, tokens chan<- Token) error {
 			}
 		default:
 			tokens <- Token{
-				Type:   TokenCharacter,
-				Value:  fmt.Sprintf("%c", char),
-				Line:   line,
-				Column: column,
+				Type:    TokenCharacter,
+				Value:   fmt.Sprintf("%c", char),
+				Line:    line,
+				Column:  column,
+				Escaped: escaped,
 			}
 			escaped = false
 		}
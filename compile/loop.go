@@ -0,0 +1,59 @@
+package compile
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Loop jumps back to Label until it has been reached Count times.
+type Loop struct {
+	Label string
+	Count int
+}
+
+func (loop Loop) String() string {
+	return fmt.Sprintf("%s %d", loop.Label, loop.Count)
+}
+
+func (loop Loop) MarshalText() ([]byte, error) {
+	return []byte(loop.String()), nil
+}
+
+// label is a new label for a loop.
+func (parser *parser) label() string {
+	parser.loops++
+	return fmt.Sprintf("loop%d", parser.loops)
+}
+
+// @repeat(N) runs the block N times.
+func repeatDecorator(parser *parser, args []string) (Decorator, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument got: %d", len(args))
+	}
+	count, err := strconv.Atoi(args[0])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf(`expected a number of times got: "%s"`, args[0])
+	}
+	return func(body []Instruction) ([]Instruction, error) {
+		if count == 0 {
+			return []Instruction{}, nil
+		}
+		label := parser.label()
+		instructions := []Instruction{{Opcode: OpLabel, Arg: label}}
+		instructions = append(instructions, body...)
+		return append(instructions, Instruction{Opcode: OpJumpIfCounter, Arg: Loop{Label: label, Count: count}}), nil
+	}, nil
+}
+
+// @forever runs the block until the program is quit, or once if nothing can stop it.
+func foreverDecorator(parser *parser, args []string) (Decorator, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("expected no arguments got: %d", len(args))
+	}
+	return func(body []Instruction) ([]Instruction, error) {
+		label := parser.label()
+		instructions := []Instruction{{Opcode: OpLabel, Arg: label}}
+		instructions = append(instructions, body...)
+		return append(instructions, Instruction{Opcode: OpJump, Arg: label}), nil
+	}, nil
+}
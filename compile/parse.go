@@ -1,18 +1,49 @@
 package compile
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-func Parse(ctx context.Context, tokens <-chan Token) (program *Program, err error) {
-	var reader = TokenReader{
-		Channel: tokens,
+type ParseOptions struct {
+	Seed uint64            `help:"Seed for randomized features such as @human and --human timing so output is reproducible. 0 picks a random seed."`
+	Vars map[string]string `name:"var" placeholder:"NAME=VALUE" help:"Set a variable used by {$NAME}, overriding the script header."`
+
+	Shell        bool          `help:"Allow {$ command} to run shell commands, the same as 'shell: enable' in the script header."`
+	ShellDir     string        `type:"path" help:"Working directory for shell commands."`
+	ShellTimeout time.Duration `help:"How long a shell command may run before it is killed, overriding the script header. Defaults to 10s."`
+	PS1          string        `name:"ps1" help:"Prompt shown before commands in a @session. Defaults to '$ '."`
+}
+
+type parser struct {
+	reader  *TokenReader
+	options ParseOptions
+	rand    *rand.Rand
+	// loops is how many loops have been given labels.
+	loops int
+}
+
+func Parse(ctx context.Context, tokens <-chan Token, options ParseOptions) (program *Program, err error) {
+	seed := options.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	parser := parser{
+		reader: &TokenReader{
+			Channel: tokens,
+		},
+		options: options,
+		rand:    rand.New(rand.NewPCG(seed, seed)),
 	}
-	instructions, err := parse(ctx, &reader)
+	instructions, err := parser.parse(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -21,10 +52,29 @@ func Parse(ctx context.Context, tokens <-chan Token) (program *Program, err erro
 	}, nil
 }
 
-func parse(ctx context.Context, reader *TokenReader) ([]Instruction, error) {
+// ParseReader lexes and parses a whole script.
+func ParseReader(ctx context.Context, reader io.Reader, options ParseOptions) (*Program, error) {
+	tokens := make(chan Token, 10)
+	lexErr := make(chan error, 1)
+	go func() {
+		defer close(tokens)
+		lexErr <- Lex(ctx, bufio.NewReader(reader), tokens)
+	}()
+
+	program, err := Parse(ctx, tokens, options)
+	for range tokens {
+		// Drain so the lexer can finish
+	}
+	if err := <-lexErr; err != nil {
+		return nil, err
+	}
+	return program, err
+}
+
+func (parser *parser) parse(ctx context.Context) ([]Instruction, error) {
 	var instructions []Instruction
 	for {
-		newInstructions, err := parseSwitch(ctx, reader, reader.Pop())
+		newInstructions, err := parser.parseSwitch(ctx, parser.reader.Pop())
 		if errors.Is(err, io.EOF) {
 			return instructions, nil
 		} else if err != nil {
@@ -34,7 +84,7 @@ func parse(ctx context.Context, reader *TokenReader) ([]Instruction, error) {
 	}
 }
 
-func parseSwitch(ctx context.Context, reader *TokenReader, token Token) ([]Instruction, error) {
+func (parser *parser) parseSwitch(ctx context.Context, token Token) ([]Instruction, error) {
 	// TODO: move into main parse function
 	switch token.Type {
 	case TokenNewline:
@@ -45,19 +95,19 @@ func parseSwitch(ctx context.Context, reader *TokenReader, token Token) ([]Instr
 			Arg:    token.Value,
 		}}, nil
 	case TokenBracketStart:
-		bracketInstructions, err := parseBracket(ctx, reader)
+		bracketInstructions, err := parser.parseBracket(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("invalid bracket section: %w", err)
 		}
 		return bracketInstructions, nil
 	case TokenCommandStart:
-		command, err := parseCommand(ctx, reader)
+		command, err := parser.parseCommand(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("invalid command: %w", err)
 		}
 		return command, nil
 	case TokenDecorator:
-		return parseDecorator(ctx, reader)
+		return parser.parseDecorator(ctx)
 	case TokenEOF:
 		return []Instruction{}, io.EOF
 	default:
@@ -65,184 +115,343 @@ func parseSwitch(ctx context.Context, reader *TokenReader, token Token) ([]Instr
 	}
 }
 
-func parseDecorator(ctx context.Context, reader *TokenReader) ([]Instruction, error) {
-	next := reader.Peek()
+func (parser *parser) parseDecorator(ctx context.Context) ([]Instruction, error) {
+	next := parser.reader.Peek()
 	if next.Type != TokenCharacter {
 		return nil, ExpectedType(next, TokenCharacter)
 	}
 
-	var before, after []Instruction
-	var err error
-	if next.Value == "(" {
-		reader.Pop()
-		before, after, err = parseDecoratorWord(ctx, reader)
-		if err != nil {
-			return nil, err
+	var decorators []Decorator
+	// Styles next to each other in the list are merged so they are pushed as one
+	var style *Style
+	flushStyle := func() {
+		if style != nil {
+			decorators = append(decorators, setStyle(*style))
+			style = nil
 		}
+	}
+	add := func(decorator Decorator, wordStyle *Style) {
+		switch {
+		case wordStyle == nil:
+			flushStyle()
+			decorators = append(decorators, decorator)
+		case style == nil:
+			style = wordStyle
+		default:
+			merged := style.With(*wordStyle)
+			style = &merged
+		}
+	}
+	if next.Value == "(" {
+		parser.reader.Pop()
 		for {
-			delim := reader.Pop()
+			decorator, wordStyle, err := parser.parseDecoratorWord(ctx)
+			if err != nil {
+				return nil, err
+			}
+			add(decorator, wordStyle)
+
+			delim := parser.reader.Pop()
 			if delim.Type != TokenCharacter {
 				return nil, ExpectedType(delim, TokenCharacter)
 			} else if delim.Value == ")" {
 				break
 			} else if delim.Value != "," {
-				return nil, fmt.Errorf("expected ')' or ',' got: %v", next)
+				return nil, fmt.Errorf("expected ')' or ',' got: %v", delim)
 			}
-
-			nextBefore, nextAfter, err := parseDecoratorWord(ctx, reader)
-			if err != nil {
-				return nil, err
-			}
-			before = append(before, nextBefore...)
-			after = append(after, nextAfter...)
 		}
 	} else {
-		nextBefore, nextAfter, err := parseDecoratorWord(ctx, reader)
+		decorator, wordStyle, err := parser.parseDecoratorWord(ctx)
 		if err != nil {
 			return nil, err
 		}
-		before = append(before, nextBefore...)
-		after = append(after, nextAfter...)
+		add(decorator, wordStyle)
 	}
+	flushStyle()
 
-	PopWhitespace(reader)
+	PopWhitespace(parser.reader)
 
 	// Parse the first {
-	if token := reader.Pop(); token.Type != TokenCommandStart {
+	if token := parser.reader.Pop(); token.Type != TokenCommandStart {
 		return nil, ExpectedType(token, TokenCommandStart)
 	}
 
-	instructions := before
+	var instructions []Instruction
 	for {
-		token := reader.Pop()
+		token := parser.reader.Pop()
 		if token.Type == TokenCommandClose {
 			break
 		}
 
-		newInstructions, err := parseSwitch(ctx, reader, token)
+		newInstructions, err := parser.parseSwitch(ctx, token)
 		if err != nil {
 			return nil, err
 		}
 		instructions = append(instructions, newInstructions...)
 	}
-	return append(instructions, after...), nil
+
+	// The first decorator is the outermost
+	for i := len(decorators) - 1; i >= 0; i-- {
+		var err error
+		instructions, err = decorators[i](instructions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return instructions, nil
 }
 
-func parseDecoratorWord(ctx context.Context, reader *TokenReader) ([]Instruction, []Instruction, error) {
+// parseDecoratorWord parses a single decorator such as red or typos(0.1). Decorators
+// that only set a style return the style instead.
+func (parser *parser) parseDecoratorWord(ctx context.Context) (Decorator, *Style, error) {
 	var buffer strings.Builder
+	var args []string
 	for {
-		next := reader.Peek()
+		next := parser.reader.Peek()
 		if next.Type == TokenCommandStart {
 			break
 		} else if next.Type != TokenCharacter {
 			return nil, nil, ExpectedType(next, TokenCharacter)
 		} else if char := next.Value; char == "," || char == ")" {
 			break
+		} else if char == "(" {
+			parser.reader.Pop()
+			var err error
+			if args, err = parser.parseDecoratorArgs(ctx); err != nil {
+				return nil, nil, err
+			}
+			break
 		}
 		buffer.WriteString(next.Value)
-		reader.Pop()
+		parser.reader.Pop()
 	}
-	decorator := buffer.String()
-	if color, ok := colorMap[decorator]; ok {
-		before, after := setColor(color)
-		return before, after, nil
+
+	name := strings.TrimSpace(buffer.String())
+	if newDecorator, ok := decorators[name]; ok {
+		decorator, err := newDecorator(parser, args)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`decorator "%s": %w`, name, err)
+		}
+		return decorator, nil, nil
+	}
+	if newStyle, ok := styleDecorators[name]; ok {
+		style, err := newStyle(args)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`decorator "%s": %w`, name, err)
+		}
+		return nil, &style, nil
 	}
-	// TODO: Implement more decorators
-	return nil, nil, fmt.Errorf(`unknown decorator: "%s"`, decorator)
+	if style, ok := parseStyleWord(name); ok && args == nil {
+		return nil, &style, nil
+	}
+	return nil, nil, fmt.Errorf(`unknown decorator: "%s"`, name)
 }
 
-func setColor(color string) ([]Instruction, []Instruction) {
-	return []Instruction{
-			{
-				Opcode: OpPushColor,
-				Arg:    color,
-			},
-		}, []Instruction{
-			{
-				Opcode: OpPopColor,
-			},
+// parseDecoratorArgs parses the comma separated arguments after the opening '('.
+func (parser *parser) parseDecoratorArgs(ctx context.Context) ([]string, error) {
+	var buffer strings.Builder
+	depth := 0
+	for {
+		next := parser.reader.Pop()
+		if next.Type != TokenCharacter {
+			return nil, ExpectedType(next, TokenCharacter)
+		} else if next.Value == "(" {
+			depth++
+		} else if next.Value == ")" {
+			if depth == 0 {
+				break
+			}
+			depth--
 		}
+		buffer.WriteString(next.Value)
+	}
+
+	args := []string{}
+	if strings.TrimSpace(buffer.String()) == "" {
+		return args, nil
+	}
+	for arg := range strings.SplitSeq(buffer.String(), ",") {
+		args = append(args, strings.TrimSpace(arg))
+	}
+	return args, nil
 }
 
-func parseCommand(ctx context.Context, reader *TokenReader) ([]Instruction, error) {
-	// TODO: Pop all the tokens until the bracket end, then parse off those tokens!
-	//       To enable a lot more types of commands
+func (parser *parser) parseCommand(ctx context.Context) ([]Instruction, error) {
+	var buffer strings.Builder
+	for {
+		next := parser.reader.Pop()
+		if next.Type == TokenCommandClose {
+			break
+		} else if next.Type != TokenCharacter {
+			return nil, fmt.Errorf("expected '}' or character got: %s", next.String())
+		}
+		buffer.WriteString(next.Value)
+	}
+
+	// Shell commands are kept as written
+	text := strings.TrimSpace(buffer.String())
+	for prefix, typed := range map[string]bool{"$ ": false, "~$ ": true} {
+		if command, ok := strings.CutPrefix(text, prefix); ok {
+			exec, err := parser.exec(strings.TrimSpace(command), typed)
+			if err != nil {
+				return nil, err
+			}
+			return []Instruction{exec}, nil
+		}
+	}
+
+	args := CommandArgs{
+		Words:  splitCommand(text),
+		Lookup: parser.variable,
+	}
 	var instructions []Instruction
 	for {
-		next := reader.Pop()
-		switch next.Type {
-		case TokenCommandClose:
-			// Happy case
+		word, ok := args.Next()
+		if !ok {
 			return instructions, nil
-		case TokenCharacter:
-			// TODO: Support multiple commands and whitespace
-			// {.. clear}
-			switch next.Value {
-			case ".":
-				instructions = append(instructions, Instruction{
-					Opcode: OpSleep,
-					Arg:    1,
-				})
-			case "c":
-				chars := []string{"l", "e", "a", "r"}
-				for _, char := range chars {
-					next := reader.Pop()
-					if next.Type != TokenCharacter {
-						// TODO: Make this error message good
-						return nil, fmt.Errorf("invalid keyword")
-					} else if next.Value != char {
-						return nil, fmt.Errorf("invalid keyword: expected '%s'", char)
-					}
-				}
-				instructions = append(instructions, Instruction{
-					Opcode: OpClear,
-				})
+		}
+		if name, ok := strings.CutPrefix(word, "$"); ok {
+			value, err := parser.variable(name)
+			if err != nil {
+				return nil, err
 			}
-		default:
-			return nil, fmt.Errorf("expected '}' or character got: %s", next.String())
+			instructions = append(instructions, Instruction{Opcode: OpPrint, Arg: value})
+			continue
 		}
+		command, ok := commands[word]
+		if !ok {
+			return nil, fmt.Errorf(`unknown command: "%s"`, word)
+		}
+		newInstructions, err := command(&args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", word, err)
+		}
+		instructions = append(instructions, newInstructions...)
 	}
 }
 
-func parseBracket(ctx context.Context, reader *TokenReader) ([]Instruction, error) {
+func (parser *parser) parseBracket(ctx context.Context) ([]Instruction, error) {
 	var instructions []Instruction
-	var chars int
 	for {
-		next := reader.Pop()
+		next := parser.reader.Pop()
 		switch next.Type {
 		case TokenBracketClose:
-			// Happy case
-			// TODO: Append the deleting command
+			count, err := visibleChars(instructions)
+			if err != nil {
+				return nil, err
+			}
 			return append(instructions, Instruction{
 				Opcode: OpDelete,
-				Arg:    chars,
+				Arg:    count,
 			}), nil
-		case TokenNewline:
-			fallthrough
 		case TokenEOF:
 			return nil, fmt.Errorf(`expected: "]" got: "%s"`, next)
-		case TokenCommandStart:
-			command, err := parseCommand(ctx, reader)
-			if err != nil {
-				return nil, fmt.Errorf("invalid command: %w", err)
+		case TokenCharacter:
+			// \-> or \~> types the arrow instead
+			if !next.Escaped && (next.Value == "-" || next.Value == "~") && parser.reader.Peek().Value == ">" {
+				parser.reader.Pop()
+				return parser.parseCorrection(ctx, instructions, next.Value == "~")
 			}
-			instructions = append(instructions, command...)
-		case TokenBracketStart:
-			bracketInstructions, err := parseBracket(ctx, reader)
+			fallthrough
+		default:
+			newInstructions, err := parser.parseSwitch(ctx, next)
 			if err != nil {
-				return nil, fmt.Errorf("invalid bracket section: %w", err)
+				return nil, err
 			}
-			instructions = append(instructions, bracketInstructions...)
-		case TokenCharacter:
-			instructions = append(instructions, Instruction{
-				Opcode: OpPrint,
-				Arg:    next.Value,
+			instructions = append(instructions, newInstructions...)
+		}
+	}
+}
+
+// parseCorrection parses the rest of [wrong->right] once wrong has been parsed. It types wrong,
+// pauses for a beat, deletes it then types right. The smart form [wrong~>right] only deletes
+// back to where the two differ.
+func (parser *parser) parseCorrection(ctx context.Context, wrong []Instruction, smart bool) ([]Instruction, error) {
+	var right []Instruction
+	for {
+		next := parser.reader.Pop()
+		if next.Type == TokenBracketClose {
+			break
+		} else if next.Type == TokenEOF {
+			return nil, fmt.Errorf(`expected: "]" got: "%s"`, next)
+		}
+		newInstructions, err := parser.parseSwitch(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		right = append(right, newInstructions...)
+	}
+
+	count, err := visibleChars(wrong)
+	if err != nil {
+		return nil, err
+	}
+	if smart {
+		wrongText, wrongOK := plainText(wrong)
+		rightText, rightOK := plainText(right)
+		if wrongOK && rightOK {
+			prefix := 0
+			for prefix < len(wrongText) && prefix < len(rightText) && wrongText[prefix] == rightText[prefix] {
+				prefix++
+			}
+			count = len(wrongText) - prefix
+			right = []Instruction{{Opcode: OpPrint, Arg: string(rightText[prefix:])}}
+		}
+	}
+
+	instructions := append(wrong,
+		Instruction{Opcode: OpSleep, Arg: 1},
+		Instruction{Opcode: OpDelete, Arg: count},
+	)
+	return append(instructions, right...), nil
+}
+
+// plainText is the text printed by instructions, if they only print.
+func plainText(instructions []Instruction) ([]rune, bool) {
+	var text []rune
+	for _, instruction := range instructions {
+		if instruction.Opcode != OpPrint {
+			return nil, false
+		}
+		text = append(text, []rune(instruction.Arg.(string))...)
+	}
+	return text, true
+}
+
+// visibleChars is how many characters are left on screen after running instructions,
+// including newlines. It fails for instructions whose effect on the screen isn't known
+// until they run.
+func visibleChars(instructions []Instruction) (int, error) {
+	var chars int
+	for i := 0; i < len(instructions); i++ {
+		switch instruction := instructions[i]; instruction.Opcode {
+		case OpPrint:
+			chars += utf8.RuneCountInString(instruction.Arg.(string))
+		case OpDelete:
+			chars -= instruction.Arg.(int)
+		case OpClear:
+			chars = 0
+		case OpExec:
+			return 0, fmt.Errorf("can't delete the output of a shell command: %s", instruction.Arg)
+		case OpCursor, OpErase:
+			return 0, fmt.Errorf("can't delete text around %s", instruction)
+		case OpLabel:
+			// Count the body of a repeat once per pass
+			end := slices.IndexFunc(instructions[i:], func(instruction Instruction) bool {
+				loop, ok := instruction.Arg.(Loop)
+				return instruction.Opcode == OpJumpIfCounter && ok && loop.Label == instructions[i].Arg
 			})
-			chars++
-		default:
-			return nil, fmt.Errorf("bracket: unknown token: %s", next.String())
+			if end != -1 {
+				body, err := visibleChars(instructions[i+1 : i+end])
+				if err != nil {
+					return 0, err
+				}
+				chars += body * instructions[i+end].Arg.(Loop).Count
+				i += end
+			}
 		}
 	}
+	return max(chars, 0), nil
 }
 
 type TokenReader struct {
@@ -0,0 +1,304 @@
+package compile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// player is the state of a running program.
+type player struct {
+	renderer Renderer
+	controls *controls
+	timing   Timing
+	options  RunOptions
+	// screen mirrors what has been drawn so animations know what is on screen.
+	screen       *Screen
+	instructions []Instruction
+	// pc is the index of the next instruction.
+	pc       int
+	labels   map[string]int
+	counters map[string]int
+	// timings are the timing models replaced by pushTiming.
+	timings []Timing
+	human   Timing
+	// speaker is started by the first @say.
+	speaker Speaker
+}
+
+// stop cuts off any speech when the program ends early with err.
+func (player *player) stop(err error) error {
+	if player.speaker != nil {
+		player.speaker.Stop() //nolint:errcheck
+	}
+	return err
+}
+
+func (player *player) step(instruction Instruction) error {
+	switch instruction.Opcode {
+	case OpPrint:
+		text := []rune(instruction.Arg.(string))
+		for i, char := range text {
+			if player.options.List && char == ' ' {
+				char = '\n'
+			}
+			var next rune
+			if i+1 < len(text) {
+				next = text[i+1]
+			} else {
+				next = player.nextRune()
+			}
+			if err := player.print(char, next); err != nil {
+				return err
+			}
+		}
+	case OpDelete:
+		for range instruction.Arg.(int) {
+			if err := player.delete(); err != nil {
+				return err
+			}
+		}
+	case OpSleep:
+		beat := player.options.Beat
+		if err := player.renderer.Sleep(time.Duration(instruction.Arg.(int)) * beat); err != nil {
+			return err
+		}
+		for range instruction.Arg.(int) {
+			if err := player.controls.wait(beat); err != nil {
+				return err
+			}
+		}
+	case OpPause:
+		duration := instruction.Arg.(time.Duration)
+		if err := player.renderer.Sleep(duration); err != nil {
+			return err
+		}
+		return player.controls.wait(duration)
+	case OpExec:
+		return player.exec(instruction.Arg.(Exec))
+	case OpWait:
+		return player.controls.waitForKey(instruction.Arg.(time.Duration), player.options.Wait)
+	case OpClear:
+		player.screen.Clear()
+		return player.renderer.Clear()
+	case OpCursor:
+		return player.move(instruction.Arg.(Cursor))
+	case OpErase:
+		return player.erase(instruction.Arg.(Erase))
+	case OpLabel:
+	case OpJump:
+		if player.controls.keys == nil && player.controls.done == nil {
+			// Nothing could ever stop the loop so it only runs once
+			return nil
+		}
+		return player.jump(instruction.Arg.(string))
+	case OpJumpIfCounter:
+		loop := instruction.Arg.(Loop)
+		player.counters[loop.Label]++
+		if player.counters[loop.Label] < loop.Count {
+			return player.jump(loop.Label)
+		}
+		// Reset so the loop runs in full again when it is nested in another
+		player.counters[loop.Label] = 0
+	case OpSpeak:
+		if player.speaker == nil {
+			speaker, err := NewCommandSpeaker(player.options.SpeakCommand)
+			if err != nil {
+				return err
+			}
+			player.speaker = speaker
+		}
+		return player.speaker.Speak(instruction.Arg.(Speech).Text)
+	case OpSpeakWait:
+		if player.speaker == nil {
+			return nil
+		}
+		said := make(chan error, 1)
+		go func() {
+			said <- player.speaker.Wait()
+		}()
+		select {
+		case err := <-said:
+			return err
+		case <-player.controls.done:
+			return ErrStopped
+		}
+	case OpPushTiming:
+		player.timings = append(player.timings, player.timing)
+		player.timing = player.modeTiming(instruction.Arg.(TimingMode))
+	case OpPopTiming:
+		if len(player.timings) == 0 {
+			return errors.New("call to popTiming when the stack is empty")
+		}
+		player.timing = player.timings[len(player.timings)-1]
+		player.timings = player.timings[:len(player.timings)-1]
+	case OpPushStyle:
+		return player.renderer.PushStyle(instruction.Arg.(Style))
+	case OpPopStyle:
+		return player.renderer.PopStyle()
+	default:
+		return fmt.Errorf("unknown op: %s", instruction.Opcode)
+	}
+	return nil
+}
+
+func (player *player) modeTiming(mode TimingMode) Timing {
+	switch mode {
+	case TimingHuman:
+		if player.human == nil {
+			options := player.options
+			options.Human = true
+			player.human = NewTiming(options)
+		}
+		return player.human
+	default:
+		return FixedTiming(0)
+	}
+}
+
+func (player *player) jump(label string) error {
+	pc, ok := player.labels[label]
+	if !ok {
+		return fmt.Errorf("unknown label: %s", label)
+	}
+	player.pc = pc
+	return nil
+}
+
+// print types a single character then waits before next.
+func (player *player) print(char rune, next rune) error {
+	if err := player.draw(char); err != nil {
+		return err
+	}
+	return player.controls.wait(player.timing.Delay(char, next))
+}
+
+// nextRune is the first character printed by the instructions after the current one,
+// or 0 if something other than styles or timing changes comes first.
+func (player *player) nextRune() rune {
+	for _, instruction := range player.instructions[player.pc:] {
+		switch instruction.Opcode {
+		case OpPrint:
+			if text := instruction.Arg.(string); text != "" {
+				char, _ := utf8.DecodeRuneInString(text)
+				return char
+			}
+		case OpPushStyle, OpPopStyle, OpPushTiming, OpPopTiming, OpLabel, OpSpeak:
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// draw shows a single character without waiting.
+func (player *player) draw(char rune) error {
+	if char == '\n' {
+		player.screen.Newline()
+		return player.renderer.Newline()
+	}
+	player.screen.Print(char)
+	return player.renderer.Print(string(char))
+}
+
+// delete backspaces a single character, which may be a newline.
+func (player *player) delete() error {
+	screen := player.screen
+	var err error
+	if screen.Column == 0 && screen.Row > 0 {
+		screen.Delete(1)
+		err = player.renderer.DeleteNewline(screen.Column)
+	} else {
+		screen.Delete(1)
+		err = player.renderer.Delete(1)
+	}
+	if err != nil {
+		return err
+	}
+	return player.controls.wait(player.timing.Delay('\b', 0))
+}
+
+func (player *player) move(cursor Cursor) error {
+	player.screen.Move(cursor)
+	return player.renderer.MoveCursor(cursor)
+}
+
+// blank types over count characters with spaces then returns to where it started.
+func (player *player) blank(count int) error {
+	if count <= 0 {
+		// The cursor is already past the end of the line
+		return nil
+	}
+	for range count {
+		player.screen.Print(' ')
+		if err := player.renderer.Print(" "); err != nil {
+			return err
+		}
+		if err := player.controls.wait(player.timing.Delay('\b', 0)); err != nil {
+			return err
+		}
+	}
+	return player.move(Cursor{Action: CursorLeft, Count: count})
+}
+
+func (player *player) erase(erase Erase) error {
+	if !erase.Animated {
+		player.screen.Erase(erase)
+		return player.renderer.Erase(erase)
+	}
+
+	screen := player.screen
+	row, column := screen.Row, screen.Column
+	switch erase.Target {
+	case EraseLine:
+		// Backspace from the end of the line to its start
+		if err := player.move(Cursor{Action: CursorColumn, Column: screen.LineLength(row) + 1}); err != nil {
+			return err
+		}
+		for screen.Column > 0 {
+			if err := player.delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case EraseEndOfLine:
+		return player.blank(screen.LineLength(row) - column)
+	case EraseEndOfScreen:
+		if err := player.blank(screen.LineLength(row) - column); err != nil {
+			return err
+		}
+		for line := row + 1; line < len(screen.Lines); line++ {
+			if err := player.move(Cursor{Action: CursorDown, Count: 1}); err != nil {
+				return err
+			}
+			if err := player.move(Cursor{Action: CursorColumn, Column: 1}); err != nil {
+				return err
+			}
+			if err := player.blank(screen.LineLength(line)); err != nil {
+				return err
+			}
+		}
+		if screen.Row != row {
+			if err := player.move(Cursor{Action: CursorUp, Count: screen.Row - row}); err != nil {
+				return err
+			}
+		}
+		return player.move(Cursor{Action: CursorColumn, Column: column + 1})
+	case EraseRegion:
+		if err := player.move(Cursor{Action: CursorSave}); err != nil {
+			return err
+		}
+		for line := range erase.Height {
+			if err := player.move(Cursor{Action: CursorGoto, Row: erase.Row + line, Column: erase.Column}); err != nil {
+				return err
+			}
+			if err := player.blank(erase.Width); err != nil {
+				return err
+			}
+		}
+		return player.move(Cursor{Action: CursorRestore})
+	default:
+		return fmt.Errorf("unknown erase: %s", erase.Target)
+	}
+}
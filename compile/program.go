@@ -1,9 +1,11 @@
 package compile
 
 import (
-	"errors"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"slices"
 	"strings"
 	"time"
 )
@@ -17,9 +19,24 @@ const (
 	OpPrint     = "print"     // print(content str)
 	OpDelete    = "delete"    // delete(count int) // Number of characters to backspace
 	OpSleep     = "sleep"     // sleep(seconds int)
+	OpPause     = "pause"     // pause(duration time.Duration)
 	OpClear     = "clear"     // clear()
-	OpPushColor = "pushColor" // pushColor(color string)
-	OpPopColor  = "popColor"  // popColor()
+	OpPushStyle = "pushStyle" // pushStyle(style Style) // Layered on top of the current style
+	OpPopStyle  = "popStyle"  // popStyle() // Restores the previous style
+	OpWait      = "wait"      // wait(timeout time.Duration) // Until a key is pressed. 0 waits forever
+	OpCursor    = "cursor"    // cursor(move Cursor)
+	OpErase     = "erase"     // erase(erase Erase)
+	OpExec      = "exec"      // exec(command Exec) // Shows the output of a shell command
+
+	OpSpeak     = "speak"     // speak(speech Speech) // Starts saying speech.Text
+	OpSpeakWait = "speakWait" // speakWait() // Until everything has been said
+
+	OpPushTiming = "pushTiming" // pushTiming(mode TimingMode) // Until the matching popTiming
+	OpPopTiming  = "popTiming"  // popTiming()
+
+	OpLabel         = "label"         // label(name string) // Where jumps go to
+	OpJump          = "jump"          // jump(label string)
+	OpJumpIfCounter = "jumpIfCounter" // jumpIfCounter(loop Loop) // Counts passes, jumping back until it reaches loop.Count
 )
 
 const (
@@ -53,62 +70,174 @@ type Program struct {
 }
 
 type RunOptions struct {
-	List  bool          `short:"l" help:"Output a single word per line."`
-	Delay time.Duration `default:"0.05s"`
-	Beat  time.Duration `default:"1s"`
+	List     bool          `short:"l" help:"Output a single word per line."`
+	Delay    time.Duration `default:"0.05s"`
+	Beat     time.Duration `default:"1s"`
+	Controls bool          `negatable:"" default:"true" help:"Enable keyboard controls when stdout is a terminal: space pauses, +/- change speed, right arrow skips and q quits (default: enabled)"`
+	Wait     time.Duration `default:"1s" help:"How long {wait} pauses without keyboard controls. SIGUSR1 also ends a {wait}."`
+	Output   string        `enum:"ansi,plain,events,srt,vtt" default:"ansi" help:"How to draw the animation: ansi for terminals, plain for the final text only, events for JSON lines of every draw call or srt and vtt for captions."`
+	Color    string        `enum:"auto,always,never" default:"auto" help:"When to use colors. auto follows NO_COLOR, FORCE_COLOR, TERM and COLORTERM and disables them when stdout is not a terminal."`
+
+	SpeakCommand string `default:"espeak" help:"Command used by @say. It is given the text to say on stdin."`
+
+	TimingOptions `embed:""`
+
+	// Keys overrides reading keyboard controls from stdin.
+	Keys <-chan Key `kong:"-"`
+	// Clock defaults to RealClock.
+	Clock Clock `kong:"-"`
+	// Renderer overrides the renderer selected by Output.
+	Renderer Renderer `kong:"-"`
+	// Timing overrides the timing model built from TimingOptions.
+	Timing Timing `kong:"-"`
+	// Done stops the program with ErrStopped once closed.
+	Done <-chan struct{} `kong:"-"`
+	// Pause pauses the program when sent true and resumes it when sent false, without
+	// turning on keyboard controls.
+	Pause <-chan bool `kong:"-"`
+	// Speaker overrides running SpeakCommand.
+	Speaker Speaker `kong:"-"`
+	// Seed for the timing model, set from --seed.
+	Seed uint64 `kong:"-"`
 }
 
 func (program Program) Run(stdout io.Writer, options RunOptions) error {
-	colors := []string{Reset}
-	for _, instruction := range program.Instructions {
-		switch instruction.Opcode {
-		case OpPrint:
-			for _, char := range instruction.Arg.(string) {
-				if options.List && char == ' ' {
-					char = '\n'
-				}
-				if _, err := fmt.Fprintf(stdout, "%c", char); err != nil {
-					return err
-				}
-				time.Sleep(options.Delay)
-			}
-		case OpDelete:
-			for range instruction.Arg.(int) {
-				if _, err := fmt.Fprint(stdout, "\b \b"); err != nil {
-					return err
-				}
-				time.Sleep(options.Delay)
-			}
-		case OpSleep:
-			for range instruction.Arg.(int) {
-				time.Sleep(options.Beat)
-			}
-		case OpClear:
-			if _, err := fmt.Fprint(stdout, ClearANSI); err != nil {
-				return err
-			}
-		case OpPushColor:
-			color := instruction.Arg.(string)
-			if _, err := fmt.Fprint(stdout, color); err != nil {
-				return err
-			}
-			colors = append(colors, color)
-		case OpPopColor:
-			if len(colors) == 0 {
-				return errors.New("call to popColor when the stack is empty")
-			}
-			reset := colors[len(colors)-2]
-			colors = colors[:len(colors)-1]
-			if _, err := fmt.Fprint(stdout, reset); err != nil {
-				return err
-			}
+	return program.run(stdout, options, true)
+}
+
+// exportedOutput reports whether output records the program on a virtual clock
+// instead of drawing it live.
+func exportedOutput(output string) bool {
+	return output == OutputEvents || output == OutputSRT || output == OutputVTT
+}
+
+// run plays the program, handling keys before each instruction when poll is set.
+func (program Program) run(stdout io.Writer, options RunOptions, poll bool) error {
+	exported := exportedOutput(options.Output)
+	if options.Keys == nil && options.Controls && !exported && keyboardAvailable(stdout) {
+		keyboard, err := OpenKeyboard()
+		if err != nil {
+			return fmt.Errorf("enabling keyboard controls: %w", err)
+		}
+		defer keyboard.Close() //nolint:errcheck
+		options.Keys = keyboard.Keys
+		stdout = RawWriter{stdout}
+	}
+
+	if exported {
+		program = *program.Exported()
+		if options.Clock == nil {
+			// Exports only need the timeline so there is no reason to wait
+			options.Clock = NewVirtualClock()
+		}
+	} else if options.Clock == nil {
+		options.Clock = RealClock{}
+	}
+	controls := newControls(options.Keys, options.Clock)
+	controls.done = options.Done
+	controls.pause = options.Pause
+	controls.polling = poll
+	// Notify with no signals would relay every signal
+	if len(waitSignals) > 0 && slices.ContainsFunc(program.Instructions, func(instruction Instruction) bool {
+		return instruction.Opcode == OpWait
+	}) {
+		triggers := make(chan os.Signal, 1)
+		signal.Notify(triggers, waitSignals...)
+		defer signal.Stop(triggers)
+		controls.triggers = triggers
+	}
+
+	timing := options.Timing
+	if timing == nil {
+		timing = NewTiming(options)
+	}
 
+	renderer := options.Renderer
+	if renderer == nil {
+		var err error
+		if renderer, err = NewRenderer(stdout, options); err != nil {
+			return err
+		}
+	}
+
+	labels, err := program.labels()
+	if err != nil {
+		return err
+	}
+
+	player := player{
+		instructions: program.Instructions,
+		renderer:     renderer,
+		controls:     controls,
+		timing:       timing,
+		options:      options,
+		screen:       &Screen{},
+		labels:       labels,
+		counters:     map[string]int{},
+		speaker:      options.Speaker,
+	}
+	for player.pc < len(program.Instructions) {
+		instruction := program.Instructions[player.pc]
+		player.pc++
+		select {
+		case <-options.Done:
+			return player.stop(ErrStopped)
 		default:
-			return fmt.Errorf("unknown op: %s", instruction.Opcode)
 		}
+		if err := controls.next(); err != nil {
+			return player.stop(err)
+		}
+		if err := player.step(instruction); err != nil {
+			return player.stop(err)
+		}
+	}
+	if player.speaker != nil {
+		if err := player.speaker.Wait(); err != nil {
+			return err
+		}
+	}
+	return renderer.Flush()
+}
 
+// labels maps each label to the index of its instruction.
+func (program Program) labels() (map[string]int, error) {
+	labels := map[string]int{}
+	for i, instruction := range program.Instructions {
+		if instruction.Opcode != OpLabel {
+			continue
+		}
+		label := instruction.Arg.(string)
+		if _, ok := labels[label]; ok {
+			return nil, fmt.Errorf("duplicate label: %s", label)
+		}
+		labels[label] = i
 	}
-	return nil
+	return labels, nil
+}
+
+// Rendered returns the program without any of its pauses so it runs straight to its final state.
+// Loops that run forever only run once.
+func (program Program) Rendered() *Program {
+	var instructions []Instruction
+	for _, instruction := range program.Instructions {
+		if !slices.Contains([]Opcode{OpSleep, OpPause, OpWait, OpJump, OpSpeak, OpSpeakWait}, instruction.Opcode) {
+			instructions = append(instructions, instruction)
+		}
+	}
+	return &Program{Instructions: instructions}
+}
+
+// Exported returns the program without anything that only makes sense while it is
+// watched. Loops that run forever only run once, shell commands aren't run and
+// nothing is said.
+func (program Program) Exported() *Program {
+	var instructions []Instruction
+	for _, instruction := range program.Instructions {
+		if !slices.Contains([]Opcode{OpJump, OpExec, OpSpeak, OpSpeakWait}, instruction.Opcode) {
+			instructions = append(instructions, instruction)
+		}
+	}
+	return &Program{Instructions: instructions}
 }
 
 type OptimizeOptions struct {
@@ -137,20 +266,32 @@ func optimize(original []Instruction, opts OptimizeOptions) ([]Instruction, bool
 	cur := &original[0]
 	for _, next := range original[1:] {
 		switch {
+		case cur.Opcode == OpPrint && cur.Arg.(string) == "":
+			// Left behind by deletes that took all of the text
+			cur = &next
 		case cur.Opcode == OpSleep && cur.Opcode == next.Opcode:
 			cur.Arg = cur.Arg.(int) + next.Arg.(int)
 		case cur.Opcode == OpPrint && cur.Opcode == next.Opcode:
 			cur.Arg = cur.Arg.(string) + next.Arg.(string)
 		case opts.Render && cur.Opcode == OpPrint && next.Opcode == OpDelete:
-			arg := cur.Arg.(string)
-			cur.Arg = arg[:len(arg)-next.Arg.(int)]
+			text := []rune(cur.Arg.(string))
+			count := min(next.Arg.(int), len(text))
+			cur.Arg = string(text[:len(text)-count])
+			if remaining := next.Arg.(int) - count; remaining > 0 {
+				// The rest of the delete is for text before a style or pause
+				if cur.Arg.(string) != "" {
+					instructions = append(instructions, *cur)
+				}
+				next.Arg = remaining
+				cur = &next
+			}
 		default:
 			instructions = append(instructions, *cur)
 			cur = &next
 		}
 	}
 
-	if cur != nil {
+	if cur != nil && (cur.Opcode != OpPrint || cur.Arg.(string) != "") {
 		instructions = append(instructions, *cur)
 	}
 	return instructions, len(instructions) != len(original)
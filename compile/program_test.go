@@ -2,6 +2,7 @@ package compile_test
 
 import (
 	"bytes"
+	"io"
 	"testing"
 	"time"
 
@@ -58,6 +59,70 @@ func TestProgramRun(t *testing.T) {
 			options:  RunOptions{Delay: 0},
 			expected: ClearANSI,
 		},
+		{
+			name: "animated clear line",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "abc"},
+					{Opcode: OpErase, Arg: Erase{Target: EraseLine, Animated: true}},
+				},
+			},
+			expected: "abc\033[4G\b \b\b \b\b \b",
+		},
+		{
+			name: "animated erase to end of line",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "hello"},
+					{Opcode: OpCursor, Arg: Cursor{Action: CursorLeft, Count: 3}},
+					{Opcode: OpErase, Arg: Erase{Target: EraseEndOfLine, Animated: true}},
+				},
+			},
+			expected: "hello\033[3D   \033[3D",
+		},
+		{
+			name: "animated erase to end of screen",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "ab\ncd"},
+					{Opcode: OpCursor, Arg: Cursor{Action: CursorGoto, Row: 1, Column: 2}},
+					{Opcode: OpErase, Arg: Erase{Target: EraseEndOfScreen, Animated: true}},
+				},
+			},
+			expected: "ab\ncd\033[1;2H \033[1D\033[1B\033[1G  \033[2D\033[1A\033[2G",
+		},
+		{
+			name: "loop",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpLabel, Arg: "loop"},
+					{Opcode: OpPrint, Arg: "a"},
+					{Opcode: OpJumpIfCounter, Arg: Loop{Label: "loop", Count: 3}},
+					{Opcode: OpPrint, Arg: "b"},
+				},
+			},
+			expected: "aaab",
+		},
+		{
+			name: "animated erase after a trailing space",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "ab "},
+					{Opcode: OpErase, Arg: Erase{Target: EraseEndOfLine, Animated: true}},
+					{Opcode: OpPrint, Arg: "c"},
+				},
+			},
+			expected: "ab c",
+		},
+		{
+			name: "shell command in a directory",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpExec, Arg: Exec{Command: "pwd", Dir: "/"}},
+				},
+			},
+			expected: "/\n",
+		},
 		{
 			name: "list mode converts spaces to newlines",
 			program: Program{
@@ -88,6 +153,35 @@ func TestProgramRun(t *testing.T) {
 	}
 }
 
+func TestProgramRunShellTimeout(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpExec, Arg: Exec{Command: "echo start; sleep 5", Timeout: 50 * time.Millisecond}},
+		},
+	}
+	var buf bytes.Buffer
+	err := program.Run(&buf, RunOptions{})
+	assert.ErrorContains(t, err, "timed out")
+	assert.Equal(t, "start\n", buf.String())
+}
+
+func TestProgramRunShellStopped(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpExec, Arg: Exec{Command: "echo start; sleep 5"}},
+		},
+	}
+	done := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(done) })
+
+	var buf bytes.Buffer
+	start := time.Now()
+	err := program.Run(&buf, RunOptions{Done: done})
+	assert.ErrorIs(t, err, ErrStopped)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, "start\n", buf.String())
+}
+
 func TestOptimize(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -136,6 +230,39 @@ func TestOptimize(t *testing.T) {
 				{Opcode: OpPrint, Arg: "tested"},
 			},
 		},
+		{
+			name: "flatten only what was printed right before the delete",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "ab"},
+					{Opcode: OpSleep, Arg: 1},
+					{Opcode: OpPrint, Arg: "cd"},
+					{Opcode: OpDelete, Arg: 3},
+				},
+			},
+			options: OptimizeOptions{Render: true},
+			expected: []Instruction{
+				{Opcode: OpPrint, Arg: "ab"},
+				{Opcode: OpSleep, Arg: 1},
+				{Opcode: OpDelete, Arg: 1},
+			},
+		},
+		{
+			name: "drop text that is all deleted",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "a"},
+					{Opcode: OpSleep, Arg: 1},
+					{Opcode: OpPrint, Arg: "bc"},
+					{Opcode: OpDelete, Arg: 2},
+				},
+			},
+			options: OptimizeOptions{Render: true},
+			expected: []Instruction{
+				{Opcode: OpPrint, Arg: "a"},
+				{Opcode: OpSleep, Arg: 1},
+			},
+		},
 		{
 			name: "disabled flatten",
 			program: Program{
@@ -204,10 +331,11 @@ func TestOptimize(t *testing.T) {
 
 func TestProgramRunTiming(t *testing.T) {
 	tests := []struct {
-		name        string
-		program     Program
-		options     RunOptions
-		minDuration time.Duration
+		name     string
+		program  Program
+		options  RunOptions
+		duration time.Duration
+		writes   []TimedWrite
 	}{
 		{
 			name: "delay between characters",
@@ -216,29 +344,252 @@ func TestProgramRunTiming(t *testing.T) {
 					{Opcode: OpPrint, Arg: "abc"},
 				},
 			},
-			options:     RunOptions{Delay: 10 * time.Millisecond},
-			minDuration: 30 * time.Millisecond,
+			options:  RunOptions{Delay: 10 * time.Millisecond},
+			duration: 30 * time.Millisecond,
+			writes: []TimedWrite{
+				{At: 0, Data: "a"},
+				{At: 10 * time.Millisecond, Data: "b"},
+				{At: 20 * time.Millisecond, Data: "c"},
+			},
+		},
+		{
+			name: "instant timing",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPushTiming, Arg: TimingInstant},
+					{Opcode: OpPrint, Arg: "ab"},
+					{Opcode: OpPopTiming},
+					{Opcode: OpPrint, Arg: "c"},
+				},
+			},
+			options:  RunOptions{Delay: 10 * time.Millisecond},
+			duration: 10 * time.Millisecond,
+			writes: []TimedWrite{
+				{At: 0, Data: "a"},
+				{At: 0, Data: "b"},
+				{At: 0, Data: "c"},
+			},
 		},
 		{
 			name: "beat for sleep",
 			program: Program{
 				Instructions: []Instruction{
 					{Opcode: OpSleep, Arg: 2},
+					{Opcode: OpPrint, Arg: "a"},
 				},
 			},
-			options:     RunOptions{Beat: 10 * time.Millisecond},
-			minDuration: 20 * time.Millisecond,
+			options:  RunOptions{Beat: 10 * time.Millisecond},
+			duration: 20 * time.Millisecond,
+			writes: []TimedWrite{
+				{At: 20 * time.Millisecond, Data: "a"},
+			},
+		},
+		{
+			name: "wait without controls",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpWait, Arg: time.Duration(0)},
+					{Opcode: OpWait, Arg: time.Second},
+					{Opcode: OpWait, Arg: time.Millisecond},
+				},
+			},
+			options:  RunOptions{Wait: 10 * time.Millisecond},
+			duration: 21 * time.Millisecond,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var buf bytes.Buffer
-			start := time.Now()
-			assert.Nil(t, tt.program.Run(&buf, tt.options))
-			duration := time.Since(start)
+			clock := NewVirtualClock()
+			recorder := Recorder{Clock: clock}
+			tt.options.Clock = clock
+			assert.Nil(t, tt.program.Run(&recorder, tt.options))
+
+			assert.Equal(t, tt.duration, clock.Elapsed())
+			assert.Equal(t, tt.writes, recorder.Writes)
+		})
+	}
+}
+
+func TestProgramRunControls(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  Program
+		keys     []Key
+		expected string
+		err      error
+	}{
+		{
+			name: "quit stops playback",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "hello"},
+					{Opcode: OpSleep, Arg: 1},
+					{Opcode: OpPrint, Arg: " world"},
+				},
+			},
+			keys:     []Key{'q'},
+			expected: "",
+			err:      ErrQuit,
+		},
+		{
+			name: "quit a loop that runs forever",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpLabel, Arg: "loop"},
+					{Opcode: OpPrint, Arg: "a"},
+					{Opcode: OpSleep, Arg: 1},
+					{Opcode: OpJump, Arg: "loop"},
+				},
+			},
+			keys:     []Key{'q'},
+			expected: "",
+			err:      ErrQuit,
+		},
+		{
+			name: "skip a sleep",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpSleep, Arg: 1},
+					{Opcode: OpPrint, Arg: "done"},
+				},
+			},
+			keys:     []Key{KeyRight},
+			expected: "done",
+		},
+		{
+			name: "pause and resume",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpPrint, Arg: "a"},
+				},
+			},
+			keys:     []Key{' ', ' '},
+			expected: "a",
+		},
+		{
+			name: "wait for a key",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpWait, Arg: time.Duration(0)},
+					{Opcode: OpPrint, Arg: "done"},
+				},
+			},
+			keys:     []Key{'x'},
+			expected: "done",
+		},
+		{
+			name: "speed up a sleep",
+			program: Program{
+				Instructions: []Instruction{
+					{Opcode: OpSleep, Arg: 1},
+					{Opcode: OpPrint, Arg: "done"},
+				},
+			},
+			keys:     []Key{'+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+', '+'},
+			expected: "done",
+		},
+	}
 
-			assert.GreaterOrEqual(t, duration, tt.minDuration)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := make(chan Key, len(tt.keys))
+			for _, key := range tt.keys {
+				keys <- key
+			}
+
+			var buf bytes.Buffer
+			err := tt.program.Run(&buf, RunOptions{
+				Beat: time.Minute,
+				Keys: keys,
+			})
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expected, buf.String())
 		})
 	}
 }
+
+func TestProgramRunQuitBusyLoop(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpLabel, Arg: "loop"},
+			{Opcode: OpPrint, Arg: "a"},
+			{Opcode: OpJump, Arg: "loop"},
+		},
+	}
+	keys := make(chan Key)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		keys <- 'q'
+	}()
+
+	// Nothing in the loop waits so the key has to be seen between instructions
+	var buf bytes.Buffer
+	err := program.Run(&buf, RunOptions{Keys: keys})
+	assert.ErrorIs(t, err, ErrQuit)
+	assert.NotEmpty(t, buf.String())
+}
+
+func TestProgramRunWaitIgnoresControls(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpWait, Arg: time.Duration(0)},
+			{Opcode: OpPrint, Arg: "done"},
+		},
+	}
+	keys := make(chan Key)
+	go func() {
+		keys <- '+'
+		keys <- '-'
+		close(keys)
+	}()
+
+	// Changing the speed doesn't end the wait, which falls back to --wait once keys end
+	clock := NewVirtualClock()
+	var buf bytes.Buffer
+	assert.NoError(t, program.Run(&buf, RunOptions{Keys: keys, Clock: clock, Wait: 10 * time.Millisecond}))
+	assert.Equal(t, "done", buf.String())
+	assert.Equal(t, 10*time.Millisecond, clock.Elapsed())
+}
+
+func TestProgramRunForeverWithoutControls(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpLabel, Arg: "loop"},
+			{Opcode: OpPrint, Arg: "a"},
+			{Opcode: OpJump, Arg: "loop"},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, program.Run(&buf, RunOptions{}))
+	assert.Equal(t, "a", buf.String())
+}
+
+// pairTiming records the characters each delay was asked for.
+type pairTiming struct {
+	pairs [][2]rune
+}
+
+func (timing *pairTiming) Delay(char, next rune) time.Duration {
+	timing.pairs = append(timing.pairs, [2]rune{char, next})
+	return 0
+}
+
+func TestProgramRunNextRune(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpPrint, Arg: "a "},
+			{Opcode: OpPushStyle, Arg: Style{Attributes: Bold}},
+			{Opcode: OpPrint, Arg: "b"},
+			{Opcode: OpPopStyle},
+			{Opcode: OpSleep, Arg: 1},
+			{Opcode: OpPrint, Arg: "c"},
+		},
+	}
+
+	// The timing sees the next character even when it is printed by a later instruction
+	timing := &pairTiming{}
+	assert.NoError(t, program.Run(io.Discard, RunOptions{Timing: timing, Output: OutputPlain}))
+	assert.Equal(t, [][2]rune{{'a', ' '}, {' ', 'b'}, {'b', 0}, {'c', 0}}, timing.pairs)
+}
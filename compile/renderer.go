@@ -0,0 +1,311 @@
+package compile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Renderer draws a running program. The program handles all timing, so Print is
+// called once per character as it is typed.
+type Renderer interface {
+	Print(text string) error
+	Newline() error
+	// Delete removes the last count characters.
+	Delete(count int) error
+	// DeleteNewline joins the current line onto the end of the previous one, which is column characters long.
+	DeleteNewline(column int) error
+	Clear() error
+	MoveCursor(cursor Cursor) error
+	// Erase instantly blanks part of the screen, animated erases are drawn with Print.
+	Erase(erase Erase) error
+	// PushStyle layers style on top of the current one until the matching PopStyle.
+	PushStyle(style Style) error
+	PopStyle() error
+	// Sleep is called before the program pauses for duration.
+	Sleep(duration time.Duration) error
+	// Flush is called once the program is done.
+	Flush() error
+}
+
+const (
+	OutputANSI   = "ansi"
+	OutputPlain  = "plain"
+	OutputEvents = "events"
+)
+
+// NewRenderer builds the renderer for options.Output, defaulting to ANSI.
+func NewRenderer(stdout io.Writer, options RunOptions) (Renderer, error) {
+	switch options.Output {
+	case OutputANSI, "":
+		renderer := NewANSIRenderer(stdout)
+		renderer.Profile = DetectColor(stdout, options.Color, os.Getenv)
+		return renderer, nil
+	case OutputPlain:
+		return &PlainRenderer{Writer: stdout}, nil
+	case OutputEvents:
+		clock := options.Clock
+		if clock == nil {
+			clock = RealClock{}
+		}
+		return NewEventRenderer(stdout, clock), nil
+	case OutputSRT, OutputVTT:
+		clock := options.Clock
+		if clock == nil {
+			clock = RealClock{}
+		}
+		return NewSubtitleRenderer(stdout, clock, options.Output), nil
+	default:
+		return nil, fmt.Errorf("unknown output: %s", options.Output)
+	}
+}
+
+// ANSIRenderer draws to a terminal using ANSI escape codes.
+type ANSIRenderer struct {
+	Writer io.Writer
+	// Profile limits the colors used, styles are dropped entirely with ProfileNone.
+	Profile ColorProfile
+	styles  []Style
+}
+
+func NewANSIRenderer(writer io.Writer) *ANSIRenderer {
+	return &ANSIRenderer{
+		Writer:  writer,
+		Profile: ProfileTrueColor,
+		styles:  []Style{{}},
+	}
+}
+
+func (renderer *ANSIRenderer) Print(text string) error {
+	_, err := io.WriteString(renderer.Writer, text)
+	return err
+}
+
+func (renderer *ANSIRenderer) Newline() error {
+	return renderer.Print("\n")
+}
+
+func (renderer *ANSIRenderer) Delete(count int) error {
+	style := renderer.style()
+	if renderer.Profile == ProfileNone || !style.visibleBlank() {
+		return renderer.Print(strings.Repeat("\b \b", count))
+	}
+	// Blank without the style so deleted text doesn't leave a background behind
+	return renderer.Print(strings.Repeat("\b"+Reset+" \b", count) + style.SGR(renderer.Profile))
+}
+
+func (renderer *ANSIRenderer) DeleteNewline(column int) error {
+	up := Cursor{Action: CursorUp, Count: 1}
+	return renderer.Print(up.ANSI() + Cursor{Action: CursorColumn, Column: column + 1}.ANSI())
+}
+
+func (renderer *ANSIRenderer) Clear() error {
+	return renderer.Print(ClearANSI)
+}
+
+func (renderer *ANSIRenderer) MoveCursor(cursor Cursor) error {
+	return renderer.Print(cursor.ANSI())
+}
+
+func (renderer *ANSIRenderer) Erase(erase Erase) error {
+	return renderer.Print(erase.ANSI())
+}
+
+func (renderer *ANSIRenderer) PushStyle(style Style) error {
+	style = renderer.style().With(style)
+	renderer.styles = append(renderer.styles, style)
+	return renderer.printStyle(style)
+}
+
+func (renderer *ANSIRenderer) PopStyle() error {
+	if len(renderer.styles) <= 1 {
+		return errors.New("call to popStyle when the stack is empty")
+	}
+	renderer.styles = renderer.styles[:len(renderer.styles)-1]
+	return renderer.printStyle(renderer.style())
+}
+
+func (renderer *ANSIRenderer) printStyle(style Style) error {
+	if renderer.Profile == ProfileNone {
+		return nil
+	}
+	return renderer.Print(style.SGR(renderer.Profile))
+}
+
+// style is the current style.
+func (renderer *ANSIRenderer) style() Style {
+	return renderer.styles[len(renderer.styles)-1]
+}
+
+func (renderer *ANSIRenderer) Sleep(duration time.Duration) error {
+	return nil
+}
+
+func (renderer *ANSIRenderer) Flush() error {
+	return nil
+}
+
+// PlainRenderer writes only the final text, a line at a time, without any escape codes.
+// Deleted characters are never written.
+type PlainRenderer struct {
+	Writer io.Writer
+	line   []rune
+}
+
+func (renderer *PlainRenderer) Print(text string) error {
+	renderer.line = append(renderer.line, []rune(text)...)
+	return nil
+}
+
+func (renderer *PlainRenderer) Newline() error {
+	renderer.line = append(renderer.line, '\n')
+	return renderer.Flush()
+}
+
+func (renderer *PlainRenderer) Delete(count int) error {
+	renderer.line = renderer.line[:max(len(renderer.line)-count, 0)]
+	return nil
+}
+
+// DeleteNewline is ignored since lines are written as soon as they end.
+func (renderer *PlainRenderer) DeleteNewline(column int) error {
+	return nil
+}
+
+func (renderer *PlainRenderer) Clear() error {
+	return renderer.Flush()
+}
+
+// MoveCursor is ignored since plain text is only ever appended to.
+func (renderer *PlainRenderer) MoveCursor(cursor Cursor) error {
+	return nil
+}
+
+// Erase only handles clearline since the rest of the screen has already been written.
+func (renderer *PlainRenderer) Erase(erase Erase) error {
+	if erase.Target == EraseLine {
+		renderer.line = nil
+	}
+	return nil
+}
+
+func (renderer *PlainRenderer) PushStyle(style Style) error {
+	return nil
+}
+
+func (renderer *PlainRenderer) PopStyle() error {
+	return nil
+}
+
+func (renderer *PlainRenderer) Sleep(duration time.Duration) error {
+	return nil
+}
+
+func (renderer *PlainRenderer) Flush() error {
+	if len(renderer.line) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(renderer.Writer, string(renderer.line))
+	renderer.line = nil
+	return err
+}
+
+type EventType string
+
+const (
+	EventPrint         EventType = "print"
+	EventNewline       EventType = "newline"
+	EventDelete        EventType = "delete"
+	EventDeleteNewline EventType = "deleteNewline"
+	EventClear         EventType = "clear"
+	EventCursor        EventType = "cursor"
+	EventErase         EventType = "erase"
+	EventPushStyle     EventType = "pushStyle"
+	EventPopStyle      EventType = "popStyle"
+	EventSleep         EventType = "sleep"
+)
+
+// Event is a single call to a Renderer and how far into the program it happened.
+type Event struct {
+	At   time.Duration `json:"at"`
+	Type EventType     `json:"type"`
+	Arg  any           `json:"arg,omitempty"`
+}
+
+// EventRenderer records every call as an Event. If Writer is set, each event is
+// also written to it as a line of JSON.
+type EventRenderer struct {
+	Writer io.Writer
+	Clock  Clock
+	Events []Event
+	start  time.Time
+}
+
+func NewEventRenderer(writer io.Writer, clock Clock) *EventRenderer {
+	return &EventRenderer{
+		Writer: writer,
+		Clock:  clock,
+		start:  clock.Now(),
+	}
+}
+
+func (renderer *EventRenderer) record(eventType EventType, arg any) error {
+	event := Event{
+		At:   renderer.Clock.Now().Sub(renderer.start),
+		Type: eventType,
+		Arg:  arg,
+	}
+	renderer.Events = append(renderer.Events, event)
+	if renderer.Writer == nil {
+		return nil
+	}
+	return json.NewEncoder(renderer.Writer).Encode(event)
+}
+
+func (renderer *EventRenderer) Print(text string) error {
+	return renderer.record(EventPrint, text)
+}
+
+func (renderer *EventRenderer) Newline() error {
+	return renderer.record(EventNewline, nil)
+}
+
+func (renderer *EventRenderer) Delete(count int) error {
+	return renderer.record(EventDelete, count)
+}
+
+func (renderer *EventRenderer) DeleteNewline(column int) error {
+	return renderer.record(EventDeleteNewline, column)
+}
+
+func (renderer *EventRenderer) Clear() error {
+	return renderer.record(EventClear, nil)
+}
+
+func (renderer *EventRenderer) MoveCursor(cursor Cursor) error {
+	return renderer.record(EventCursor, cursor)
+}
+
+func (renderer *EventRenderer) Erase(erase Erase) error {
+	return renderer.record(EventErase, erase)
+}
+
+func (renderer *EventRenderer) PushStyle(style Style) error {
+	return renderer.record(EventPushStyle, style)
+}
+
+func (renderer *EventRenderer) PopStyle() error {
+	return renderer.record(EventPopStyle, nil)
+}
+
+func (renderer *EventRenderer) Sleep(duration time.Duration) error {
+	return renderer.record(EventSleep, duration)
+}
+
+func (renderer *EventRenderer) Flush() error {
+	return nil
+}
@@ -0,0 +1,185 @@
+package compile_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rendererProgram = Program{
+	Instructions: []Instruction{
+		{Opcode: OpPushStyle, Arg: Style{Foreground: Basic(1)}},
+		{Opcode: OpPrint, Arg: "hi\n"},
+		{Opcode: OpPopStyle},
+		{Opcode: OpPrint, Arg: "abc"},
+		{Opcode: OpDelete, Arg: 2},
+		{Opcode: OpSleep, Arg: 1},
+		{Opcode: OpClear},
+		{Opcode: OpPrint, Arg: "x"},
+	},
+}
+
+func TestRenderers(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{
+			output:   OutputANSI,
+			expected: Red + "hi\n" + Reset + "abc\b \b\b \b" + ClearANSI + "x",
+		},
+		{
+			output:   OutputPlain,
+			expected: "hi\nax",
+		},
+		{
+			output: OutputSRT,
+			expected: `1
+00:00:00,010 --> 00:00:00,070
+hi
+
+2
+00:00:00,070 --> 00:00:01,080
+a
+
+3
+00:00:01,080 --> 00:00:03,080
+x
+
+`,
+		},
+		{
+			output: OutputVTT,
+			expected: `WEBVTT
+
+00:00:00.010 --> 00:00:00.070
+hi
+
+00:00:00.070 --> 00:00:01.080
+a
+
+00:00:01.080 --> 00:00:03.080
+x
+
+`,
+		},
+		{
+			output: OutputEvents,
+			expected: `{"at":0,"type":"pushStyle","arg":"fg=ansi(1)"}
+{"at":0,"type":"print","arg":"h"}
+{"at":10000000,"type":"print","arg":"i"}
+{"at":20000000,"type":"newline"}
+{"at":30000000,"type":"popStyle"}
+{"at":30000000,"type":"print","arg":"a"}
+{"at":40000000,"type":"print","arg":"b"}
+{"at":50000000,"type":"print","arg":"c"}
+{"at":60000000,"type":"delete","arg":1}
+{"at":70000000,"type":"delete","arg":1}
+{"at":80000000,"type":"sleep","arg":1000000000}
+{"at":1080000000,"type":"clear"}
+{"at":1080000000,"type":"print","arg":"x"}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := rendererProgram.Run(&buf, RunOptions{
+				Delay:  10 * time.Millisecond,
+				Beat:   time.Second,
+				Output: tt.output,
+				Color:  ColorAlways,
+				Clock:  NewVirtualClock(),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestEventRenderer(t *testing.T) {
+	clock := NewVirtualClock()
+	renderer := NewEventRenderer(nil, clock)
+	err := rendererProgram.Run(nil, RunOptions{
+		Delay:    10 * time.Millisecond,
+		Renderer: renderer,
+		Clock:    clock,
+	})
+	require.NoError(t, err)
+	assert.Len(t, renderer.Events, 13)
+	assert.Equal(t, Event{At: 60 * time.Millisecond, Type: EventDelete, Arg: 1}, renderer.Events[8])
+}
+
+func TestANSIRendererPopEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, NewANSIRenderer(&buf).PopStyle())
+}
+
+func TestEventsExport(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpSleep, Arg: 2},
+			{Opcode: OpPrint, Arg: "x"},
+		},
+	}
+
+	// Without a clock the events are timed on a virtual one
+	var buf bytes.Buffer
+	start := time.Now()
+	require.NoError(t, program.Run(&buf, RunOptions{Beat: time.Second, Output: OutputEvents}))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, `{"at":0,"type":"sleep","arg":2000000000}
+{"at":2000000000,"type":"print","arg":"x"}
+`, buf.String())
+}
+
+func TestSubtitleExport(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpLabel, Arg: "loop0"},
+			{Opcode: OpSpeak, Arg: Speech{Text: "x", Wait: true}},
+			{Opcode: OpPrint, Arg: "x"},
+			{Opcode: OpExec, Arg: Exec{Command: "exit 1"}},
+			{Opcode: OpSpeakWait},
+			{Opcode: OpSleep, Arg: 1},
+			{Opcode: OpJump, Arg: "loop0"},
+		},
+	}
+
+	// The loop runs once, the command isn't run and nothing is said
+	var buf bytes.Buffer
+	err := program.Run(&buf, RunOptions{
+		Beat:         time.Second,
+		Output:       OutputSRT,
+		SpeakCommand: "false",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:02,000\nx\n\n", buf.String())
+}
+
+func TestSubtitleRendererDeleteNewline(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			{Opcode: OpPrint, Arg: "one\ntwo\nthree"},
+			{Opcode: OpDelete, Arg: 6},
+			{Opcode: OpPrint, Arg: "!\n"},
+		},
+	}
+	clock := NewVirtualClock()
+	renderer := NewSubtitleRenderer(nil, clock, OutputSRT)
+	err := program.Run(nil, RunOptions{
+		Delay:    10 * time.Millisecond,
+		Renderer: renderer,
+		Clock:    clock,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Cue{
+		{Start: 20 * time.Millisecond, End: 190 * time.Millisecond, Text: "one"},
+		{Start: 190 * time.Millisecond, End: 2190 * time.Millisecond, Text: "two!"},
+	}, renderer.Cues)
+}
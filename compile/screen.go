@@ -0,0 +1,149 @@
+package compile
+
+import "strings"
+
+// Screen tracks the text on a terminal as a program draws it. Row and Column start at 0.
+type Screen struct {
+	Lines  [][]rune
+	Row    int
+	Column int
+	saved  [2]int
+	// ends is the column each row was left at by a newline, which may be past
+	// trailing blanks that were typed.
+	ends map[int]int
+}
+
+func (screen *Screen) line(row int) []rune {
+	if row < len(screen.Lines) {
+		return screen.Lines[row]
+	}
+	return nil
+}
+
+// set writes char at row and column, growing the screen as needed.
+func (screen *Screen) set(row, column int, char rune) {
+	for len(screen.Lines) <= row {
+		screen.Lines = append(screen.Lines, nil)
+	}
+	line := screen.Lines[row]
+	for len(line) <= column {
+		line = append(line, ' ')
+	}
+	line[column] = char
+	screen.Lines[row] = line
+}
+
+// trim drops trailing blanks from row.
+func (screen *Screen) trim(row int) {
+	if row < len(screen.Lines) {
+		screen.Lines[row] = []rune(strings.TrimRight(string(screen.Lines[row]), " "))
+	}
+}
+
+// LineLength is the length of row without trailing blanks.
+func (screen *Screen) LineLength(row int) int {
+	return len([]rune(strings.TrimRight(string(screen.line(row)), " ")))
+}
+
+func (screen *Screen) Print(char rune) {
+	screen.set(screen.Row, screen.Column, char)
+	screen.Column++
+}
+
+func (screen *Screen) Newline() {
+	if screen.ends == nil {
+		screen.ends = map[int]int{}
+	}
+	screen.ends[screen.Row] = screen.Column
+	screen.Row++
+	screen.Column = 0
+	for len(screen.Lines) <= screen.Row {
+		screen.Lines = append(screen.Lines, nil)
+	}
+}
+
+// Delete backspaces over count characters. Deleting at the start of a line joins it
+// back onto the end of the previous line.
+func (screen *Screen) Delete(count int) {
+	for range count {
+		if screen.Column == 0 {
+			if screen.Row == 0 {
+				return
+			}
+			screen.Row--
+			end, ok := screen.ends[screen.Row]
+			if !ok {
+				end = screen.LineLength(screen.Row)
+			}
+			screen.Column = end
+			continue
+		}
+		screen.Column--
+		if screen.Column < len(screen.line(screen.Row)) {
+			screen.Lines[screen.Row][screen.Column] = ' '
+		}
+		screen.trim(screen.Row)
+	}
+}
+
+func (screen *Screen) Clear() {
+	*screen = Screen{}
+}
+
+func (screen *Screen) Move(cursor Cursor) {
+	switch cursor.Action {
+	case CursorUp:
+		screen.Row = max(screen.Row-cursor.Count, 0)
+	case CursorDown:
+		screen.Row += cursor.Count
+	case CursorRight:
+		screen.Column += cursor.Count
+	case CursorLeft:
+		screen.Column = max(screen.Column-cursor.Count, 0)
+	case CursorHome:
+		screen.Row, screen.Column = 0, 0
+	case CursorColumn:
+		screen.Column = max(cursor.Column-1, 0)
+	case CursorGoto:
+		screen.Row, screen.Column = max(cursor.Row-1, 0), max(cursor.Column-1, 0)
+	case CursorSave:
+		screen.saved = [2]int{screen.Row, screen.Column}
+	case CursorRestore:
+		screen.Row, screen.Column = screen.saved[0], screen.saved[1]
+	}
+}
+
+func (screen *Screen) Erase(erase Erase) {
+	switch erase.Target {
+	case EraseLine:
+		if screen.Row < len(screen.Lines) {
+			screen.Lines[screen.Row] = nil
+		}
+		screen.Column = 0
+	case EraseEndOfLine:
+		if line := screen.line(screen.Row); screen.Column < len(line) {
+			screen.Lines[screen.Row] = line[:screen.Column]
+		}
+	case EraseEndOfScreen:
+		screen.Erase(Erase{Target: EraseEndOfLine})
+		if screen.Row+1 < len(screen.Lines) {
+			screen.Lines = screen.Lines[:screen.Row+1]
+		}
+	case EraseRegion:
+		for row := erase.Row - 1; row < erase.Row-1+erase.Height && row < len(screen.Lines); row++ {
+			for column := erase.Column - 1; column < erase.Column-1+erase.Width && column < len(screen.Lines[row]); column++ {
+				screen.Lines[row][column] = ' '
+			}
+			screen.trim(row)
+		}
+	}
+}
+
+// String is the text on the screen with trailing blank lines removed.
+func (screen *Screen) String() string {
+	lines := make([]string, len(screen.Lines))
+	for i, line := range screen.Lines {
+		lines[i] = string(line)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,87 @@
+package compile_test
+
+import (
+	"testing"
+
+	. "github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreen(t *testing.T) {
+	print := func(screen *Screen, text string) {
+		for _, char := range text {
+			if char == '\n' {
+				screen.Newline()
+			} else {
+				screen.Print(char)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		run      func(screen *Screen)
+		expected string
+	}{
+		{
+			name: "overwrite after moving",
+			run: func(screen *Screen) {
+				print(screen, "hello\nworld")
+				screen.Move(Cursor{Action: CursorGoto, Row: 1, Column: 2})
+				print(screen, "EL")
+			},
+			expected: "hELlo\nworld",
+		},
+		{
+			name: "delete",
+			run: func(screen *Screen) {
+				print(screen, "hello")
+				screen.Delete(2)
+			},
+			expected: "hel",
+		},
+		{
+			name: "delete a newline after trailing spaces",
+			run: func(screen *Screen) {
+				print(screen, "ab  \nc")
+				screen.Delete(2)
+				print(screen, "d")
+			},
+			expected: "ab  d",
+		},
+		{
+			name: "clear line",
+			run: func(screen *Screen) {
+				print(screen, "one\ntwo")
+				screen.Erase(Erase{Target: EraseLine})
+				print(screen, "2")
+			},
+			expected: "one\n2",
+		},
+		{
+			name: "end of screen",
+			run: func(screen *Screen) {
+				print(screen, "one\ntwo\nthree")
+				screen.Move(Cursor{Action: CursorGoto, Row: 1, Column: 2})
+				screen.Erase(Erase{Target: EraseEndOfScreen})
+			},
+			expected: "o",
+		},
+		{
+			name: "region",
+			run: func(screen *Screen) {
+				print(screen, "abcd\nefgh\nijkl")
+				screen.Erase(Erase{Target: EraseRegion, Row: 1, Column: 2, Height: 2, Width: 2})
+			},
+			expected: "a  d\ne  h\nijkl",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var screen Screen
+			tt.run(&screen)
+			assert.Equal(t, tt.expected, screen.String())
+		})
+	}
+}
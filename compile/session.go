@@ -0,0 +1,109 @@
+package compile
+
+import (
+	"fmt"
+	"strings"
+)
+
+type TimingMode string
+
+const (
+	TimingInstant TimingMode = "instant"
+	TimingHuman   TimingMode = "human"
+)
+
+const (
+	// CommandPrefix starts the lines of a @session that are typed as commands.
+	CommandPrefix = "$ "
+	DefaultPS1    = "$ "
+)
+
+// @session or @session(PS1) shows the block as a shell session. Lines starting with "$ " are
+// commands typed at human speed after the prompt and every other line is output that appears
+// at once. With shell commands enabled, each command is run and its real output replaces the
+// lines written after it.
+func sessionDecorator(parser *parser, args []string) (Decorator, error) {
+	ps1 := parser.options.PS1
+	switch len(args) {
+	case 0:
+	case 1:
+		ps1 = args[0]
+	default:
+		return nil, fmt.Errorf("expected at most 1 argument got: %d", len(args))
+	}
+	if ps1 == "" {
+		ps1 = DefaultPS1
+	} else if !strings.HasSuffix(ps1, " ") {
+		ps1 += " "
+	}
+
+	return func(body []Instruction) ([]Instruction, error) {
+		var instructions []Instruction
+		ran := false
+		for _, line := range splitLines(body) {
+			text, isCommand := "", false
+			if line[0].Opcode == OpPrint {
+				text, isCommand = strings.CutPrefix(line[0].Arg.(string), CommandPrefix)
+			}
+			if !isCommand {
+				if !ran {
+					instructions = append(instructions, withTiming(TimingInstant, line)...)
+				}
+				continue
+			}
+
+			instructions = append(instructions, withTiming(TimingInstant, []Instruction{{Opcode: OpPrint, Arg: ps1}})...)
+			typed := append([]Instruction{{Opcode: OpPrint, Arg: text}}, line[1:]...)
+			instructions = append(instructions, withTiming(TimingHuman, typed)...)
+			if parser.options.Shell {
+				command, ok := plainText(typed)
+				if !ok {
+					return nil, fmt.Errorf("can't run a command containing commands or decorators: %s%s", CommandPrefix, strings.TrimSpace(text))
+				}
+				exec, err := parser.exec(strings.TrimSpace(string(command)), false)
+				if err != nil {
+					return nil, err
+				}
+				instructions = append(instructions, exec)
+				ran = true
+			}
+		}
+		return instructions, nil
+	}, nil
+}
+
+func withTiming(mode TimingMode, body []Instruction) []Instruction {
+	instructions := []Instruction{{Opcode: OpPushTiming, Arg: mode}}
+	instructions = append(instructions, body...)
+	return append(instructions, Instruction{Opcode: OpPopTiming})
+}
+
+// splitLines splits instructions after each newline, joining the text printed on each line.
+func splitLines(instructions []Instruction) [][]Instruction {
+	var lines [][]Instruction
+	var line []Instruction
+	for _, instruction := range instructions {
+		if instruction.Opcode != OpPrint {
+			line = append(line, instruction)
+			continue
+		}
+		for piece := range strings.SplitAfterSeq(instruction.Arg.(string), "\n") {
+			if piece == "" {
+				continue
+			}
+			if last := len(line) - 1; last >= 0 && line[last].Opcode == OpPrint {
+				line[last].Arg = line[last].Arg.(string) + piece
+			} else {
+				line = append(line, Instruction{Opcode: OpPrint, Arg: piece})
+			}
+			if strings.HasSuffix(piece, "\n") {
+				lines = append(lines, line)
+				line = nil
+			}
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package compile
+
+import "os"
+
+var waitSignals = []os.Signal{}
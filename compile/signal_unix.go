@@ -0,0 +1,10 @@
+//go:build unix
+
+package compile
+
+import (
+	"os"
+	"syscall"
+)
+
+var waitSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,153 @@
+package compile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const SlideSeparator = "---"
+
+// SplitSlides splits a script into slides at lines only containing "---".
+func SplitSlides(reader io.Reader) ([]string, error) {
+	var slides []string
+	var slide strings.Builder
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == SlideSeparator {
+			slides = append(slides, slide.String())
+			slide.Reset()
+			continue
+		}
+		slide.WriteString(line)
+		slide.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return append(slides, slide.String()), nil
+}
+
+// Deck is a presentation of programs shown one at a time on a cleared screen.
+type Deck struct {
+	Slides []*Program
+	// Replay types out revisited slides again instead of showing their final state.
+	Replay bool
+}
+
+// Play shows the slides. The right arrow, space or enter go forward, the left arrow goes back,
+// a number followed by enter jumps to that slide, r replays the current slide and q quits.
+// Without keyboard controls every slide is played once in order.
+func (deck Deck) Play(stdout io.Writer, options RunOptions) error {
+	if len(deck.Slides) == 0 {
+		return nil
+	}
+
+	if options.Keys == nil && options.Controls && !exportedOutput(options.Output) && keyboardAvailable(stdout) {
+		keyboard, err := OpenKeyboard()
+		if err != nil {
+			return fmt.Errorf("enabling keyboard controls: %w", err)
+		}
+		defer keyboard.Close() //nolint:errcheck
+		options.Keys = keyboard.Keys
+		stdout = RawWriter{stdout}
+	}
+
+	if options.Keys == nil {
+		clock := options.Clock
+		if clock == nil {
+			clock = RealClock{}
+		}
+		for i, slide := range deck.Slides {
+			if i > 0 {
+				select {
+				case <-clock.After(options.Wait):
+				case <-options.Done:
+					return ErrStopped
+				}
+			}
+			if err := deck.show(stdout, slide, options, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	seen := make([]bool, len(deck.Slides))
+	current, replay := 0, true
+	for {
+		if err := deck.show(stdout, deck.Slides[current], options, replay); errors.Is(err, ErrQuit) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		seen[current] = true
+
+		next, err := deck.navigate(current, options.Keys, options.Done)
+		if errors.Is(err, ErrQuit) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		replay = next == current || deck.Replay || !seen[next]
+		current = next
+	}
+}
+
+func (deck Deck) show(stdout io.Writer, slide *Program, options RunOptions, replay bool) error {
+	if !replay {
+		slide = slide.Rendered()
+		// Draw it at once without touching the keys, keeping everything else such as colors
+		options.Delay, options.Beat = 0, 0
+		options.TimingOptions = TimingOptions{}
+		options.Timing = nil
+		options.Controls, options.Keys = false, nil
+	}
+	cleared := Program{Instructions: append([]Instruction{{Opcode: OpClear}}, slide.Instructions...)}
+	// Keys are only handled while the slide waits so the rest are left for navigation
+	return cleared.run(stdout, options, false)
+}
+
+// navigate waits for a key that moves to another slide.
+func (deck Deck) navigate(current int, keys <-chan Key, done <-chan struct{}) (int, error) {
+	var number strings.Builder
+	for {
+		var key Key
+		select {
+		case <-done:
+			return 0, ErrStopped
+		case next, ok := <-keys:
+			if !ok {
+				return 0, ErrQuit
+			}
+			key = next
+		}
+
+		switch {
+		case key >= '0' && key <= '9':
+			number.WriteRune(rune(key))
+			continue
+		case key == KeyEnter && number.Len() > 0:
+			if n, err := strconv.Atoi(number.String()); err == nil && n >= 1 && n <= len(deck.Slides) {
+				return n - 1, nil
+			}
+		case key == KeyRight || key == KeyDown || key == KeyEnter || key == ' ':
+			if current+1 < len(deck.Slides) {
+				return current + 1, nil
+			}
+		case key == KeyLeft || key == KeyUp:
+			if current > 0 {
+				return current - 1, nil
+			}
+		case key == 'r':
+			return current, nil
+		case key == 'q' || key == KeyInterrupt:
+			return 0, ErrQuit
+		}
+		number.Reset()
+	}
+}
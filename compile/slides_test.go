@@ -0,0 +1,100 @@
+package compile_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSlides(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "one", expected: []string{"one\n"}},
+		{input: "one\n---\ntwo\n", expected: []string{"one\n", "two\n"}},
+		{input: "one\n  ---  \ntwo\n---\n", expected: []string{"one\n", "two\n", ""}},
+		{input: "a --- b", expected: []string{"a --- b\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			slides, err := SplitSlides(strings.NewReader(tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, slides)
+		})
+	}
+}
+
+func TestDeckPlay(t *testing.T) {
+	one := &Program{Instructions: []Instruction{
+		{Opcode: OpPrint, Arg: "one"},
+		{Opcode: OpWait, Arg: 0 * time.Second},
+	}}
+	two := &Program{Instructions: []Instruction{{Opcode: OpPrint, Arg: "two"}}}
+	three := &Program{Instructions: []Instruction{{Opcode: OpPrint, Arg: "three"}}}
+
+	tests := []struct {
+		name     string
+		keys     []Key
+		expected string
+	}{
+		{
+			name:     "next and previous",
+			keys:     []Key{'x', KeyRight, KeyLeft, 'q'},
+			expected: ClearANSI + "one" + ClearANSI + "two" + ClearANSI + "one",
+		},
+		{
+			name:     "jump to a slide",
+			keys:     []Key{'x', '3', KeyEnter, 'q'},
+			expected: ClearANSI + "one" + ClearANSI + "three",
+		},
+		{
+			name:     "stay on the last slide",
+			keys:     []Key{'x', KeyRight, KeyRight, KeyRight, 'r', 'q'},
+			expected: ClearANSI + "one" + ClearANSI + "two" + ClearANSI + "three" + ClearANSI + "three",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := make(chan Key, len(tt.keys))
+			for _, key := range tt.keys {
+				keys <- key
+			}
+
+			var buf bytes.Buffer
+			deck := Deck{Slides: []*Program{one, two, three}}
+			assert.NoError(t, deck.Play(&buf, RunOptions{Keys: keys}))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestDeckPlayRevisitColor(t *testing.T) {
+	red := &Program{Instructions: []Instruction{
+		{Opcode: OpPushStyle, Arg: Style{Foreground: Basic(1)}},
+		{Opcode: OpPrint, Arg: "one"},
+		{Opcode: OpPopStyle},
+	}}
+	two := &Program{Instructions: []Instruction{{Opcode: OpPrint, Arg: "two"}}}
+	keys := make(chan Key, 3)
+	for _, key := range []Key{KeyRight, KeyLeft, 'q'} {
+		keys <- key
+	}
+
+	// The revisited slide is drawn in its final state with the same colors
+	var buf bytes.Buffer
+	deck := Deck{Slides: []*Program{red, two}}
+	require.NoError(t, deck.Play(&buf, RunOptions{Keys: keys, Color: ColorAlways}))
+	first, _, ok := strings.Cut(strings.TrimPrefix(buf.String(), ClearANSI), ClearANSI)
+	require.True(t, ok)
+	assert.Equal(t, ClearANSI+first+ClearANSI+"two"+ClearANSI+first, buf.String())
+	assert.Contains(t, first, "one")
+	assert.NotEqual(t, "one", first)
+}
@@ -0,0 +1,149 @@
+package compile
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Speaker says the text of @say blocks aloud as they are typed.
+type Speaker interface {
+	// Speak queues text to be said once everything before it has been said, without
+	// waiting for it.
+	Speak(text string) error
+	// Wait blocks until everything has been said.
+	Wait() error
+	// Stop cuts off anything still being said.
+	Stop() error
+}
+
+// CommandSpeaker runs an espeak compatible command for each piece of text, writing the text
+// to its stdin.
+type CommandSpeaker struct {
+	Command []string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// last is closed once the most recent text has been said.
+	last  chan struct{}
+	mutex sync.Mutex
+	err   error
+}
+
+func NewCommandSpeaker(command string) (*CommandSpeaker, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("no speech command")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CommandSpeaker{Command: fields, ctx: ctx, cancel: cancel}, nil
+}
+
+func (speaker *CommandSpeaker) Speak(text string) error {
+	if err := speaker.failed(); err != nil {
+		return err
+	}
+	previous, done := speaker.last, make(chan struct{})
+	speaker.last = done
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		if speaker.ctx.Err() != nil {
+			return
+		}
+		cmd := exec.CommandContext(speaker.ctx, speaker.Command[0], speaker.Command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil && speaker.ctx.Err() == nil {
+			speaker.mutex.Lock()
+			speaker.err = cmp.Or(speaker.err, fmt.Errorf("speaking: %w", err))
+			speaker.mutex.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (speaker *CommandSpeaker) Wait() error {
+	if speaker.last != nil {
+		<-speaker.last
+	}
+	err := speaker.failed()
+	speaker.mutex.Lock()
+	speaker.err = nil
+	speaker.mutex.Unlock()
+	return err
+}
+
+func (speaker *CommandSpeaker) Stop() error {
+	speaker.cancel()
+	return speaker.Wait()
+}
+
+// failed is the first error from a command that has finished.
+func (speaker *CommandSpeaker) failed() error {
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+	return speaker.err
+}
+
+// Speech is the text of a @say block.
+type Speech struct {
+	Text string
+	// Wait holds the end of the block until the text has been said.
+	Wait bool
+}
+
+func (speech Speech) String() string {
+	if !speech.Wait {
+		return fmt.Sprintf("%q overlap", speech.Text)
+	}
+	return fmt.Sprintf("%q", speech.Text)
+}
+
+func (speech Speech) MarshalText() ([]byte, error) {
+	return []byte(speech.String()), nil
+}
+
+// @say says each line of the block as it starts being typed and waits at the end of the block
+// until it has all been said. @say(overlap) carries on without waiting. Deleted text is never said.
+func sayDecorator(parser *parser, args []string) (Decorator, error) {
+	wait := true
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "overlap":
+		wait = false
+	default:
+		return nil, fmt.Errorf(`expected no arguments or "overlap" got: %s`, strings.Join(args, ", "))
+	}
+	return func(body []Instruction) ([]Instruction, error) {
+		var instructions []Instruction
+		for _, line := range splitLines(body) {
+			if text := finalText(line); text != "" {
+				instructions = append(instructions, Instruction{Opcode: OpSpeak, Arg: Speech{Text: text, Wait: wait}})
+			}
+			instructions = append(instructions, line...)
+		}
+		if wait {
+			instructions = append(instructions, Instruction{Opcode: OpSpeakWait})
+		}
+		return instructions, nil
+	}, nil
+}
+
+// finalText is the text left after running instructions.
+func finalText(instructions []Instruction) string {
+	var text []rune
+	for _, instruction := range instructions {
+		switch instruction.Opcode {
+		case OpPrint:
+			text = append(text, []rune(instruction.Arg.(string))...)
+		case OpDelete:
+			text = text[:max(len(text)-instruction.Arg.(int), 0)]
+		}
+	}
+	return strings.TrimSpace(string(text))
+}
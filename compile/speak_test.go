@@ -0,0 +1,69 @@
+package compile_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSay(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	log := filepath.Join(dir, "said")
+	espeak := filepath.Join(dir, "espeak")
+	script := "#!/bin/sh\n{ printf '%s|' \"$1\"; cat; echo; } >> " + log + "\n"
+	require.NoError(os.WriteFile(espeak, []byte(script), 0o755))
+
+	program, err := compile.ParseReader(
+		t.Context(),
+		strings.NewReader("@say{Hel[l]lo there} @say(overlap){[oops]bye}"),
+		compile.ParseOptions{},
+	)
+	require.NoError(err)
+
+	var output strings.Builder
+	require.NoError(program.Run(&output, compile.RunOptions{
+		Output:       compile.OutputPlain,
+		SpeakCommand: espeak + " --punct=none",
+	}))
+	require.Equal("Hello there bye", output.String())
+
+	said, err := os.ReadFile(log)
+	require.NoError(err)
+	require.Equal("--punct=none|Hello there\n--punct=none|bye\n", string(said))
+}
+
+func TestSayLines(t *testing.T) {
+	program, err := compile.ParseReader(t.Context(), strings.NewReader("@say(overlap){one\ntwo}"), compile.ParseOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []compile.Instruction{
+		{Opcode: compile.OpSpeak, Arg: compile.Speech{Text: "one"}},
+		{Opcode: compile.OpPrint, Arg: "one\n"},
+		{Opcode: compile.OpSpeak, Arg: compile.Speech{Text: "two"}},
+		{Opcode: compile.OpPrint, Arg: "two"},
+	}, program.Instructions)
+}
+
+func TestSayStopped(t *testing.T) {
+	require := require.New(t)
+	program, err := compile.ParseReader(t.Context(), strings.NewReader("@say{hi}"), compile.ParseOptions{})
+	require.NoError(err)
+	done := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(done) })
+
+	// Stopping cuts off the speech instead of waiting for it
+	start := time.Now()
+	err = program.Run(io.Discard, compile.RunOptions{
+		Output:       compile.OutputPlain,
+		SpeakCommand: "sleep 5",
+		Done:         done,
+	})
+	require.ErrorIs(err, compile.ErrStopped)
+	require.Less(time.Since(start), time.Second)
+}
@@ -0,0 +1,209 @@
+package compile
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// standaloneMagic ends a standalone executable, after the embedded script and its length.
+const standaloneMagic = "\x00textly-standalone"
+
+// Build writes an executable that plays a script without needing textly or the script.
+type Build struct {
+	Input      string `arg:"" name:"input" help:"Script to embed. - reads it from stdin."`
+	Output     string `short:"o" help:"Executable to write. Defaults to the script name without its extension."`
+	Standalone bool   `help:"Copy this executable with the script appended to it. Currently the only kind of build."`
+
+	// ParseOptions are embedded with the script.
+	ParseOptions ParseOptions `embed:""`
+
+	// Stdin is read for the input -, defaulting to os.Stdin.
+	Stdin io.Reader `kong:"-"`
+	// Executable is copied instead of the running executable.
+	Executable string `kong:"-"`
+}
+
+func (cmd *Build) Run(ctx context.Context, stdout io.Writer) error {
+	if !cmd.Standalone {
+		return errors.New("only --standalone builds are supported")
+	}
+
+	output := cmd.Output
+	if output == "" {
+		if cmd.Input == StdinInput {
+			return errors.New("--output is required when reading the script from stdin")
+		}
+		output = strings.TrimSuffix(filepath.Base(cmd.Input), filepath.Ext(cmd.Input))
+	}
+	if sameFile(output, cmd.Input) {
+		return fmt.Errorf("refusing to overwrite the script %s, pick another --output", cmd.Input)
+	}
+
+	input, err := Scene{Path: cmd.Input}.Open(cmd.Stdin)
+	if err != nil {
+		return err
+	}
+	defer input.Close() //nolint:errcheck
+	script, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	embedded := EmbeddedScript{
+		Name:    filepath.Base(output),
+		Script:  script,
+		Options: cmd.ParseOptions,
+	}
+	if cmd.Input != StdinInput {
+		embedded.Name = strings.TrimSuffix(filepath.Base(cmd.Input), filepath.Ext(cmd.Input))
+	}
+	// Catch mistakes now rather than when the executable is run
+	if _, err := compileScript(ctx, bytes.NewReader(script), cmd.ParseOptions, false, OptimizeOptions{}); err != nil {
+		return fmt.Errorf("%s: %w", embedded.Name, err)
+	}
+
+	executable := cmd.Executable
+	if executable == "" {
+		if executable, err = os.Executable(); err != nil {
+			return err
+		}
+	}
+	data, err := os.ReadFile(executable)
+	if err != nil {
+		return err
+	}
+	// Rebuilding from a standalone executable replaces its script
+	data = data[:len(data)-standaloneSize(data)]
+
+	standalone, err := AppendScript(data, embedded)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, standalone, 0o755); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of a file that already existed
+	if err := os.Chmod(output, 0o755); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "wrote %s\n", output)
+	return err
+}
+
+// sameFile reports whether path and other are the same existing file.
+func sameFile(path string, other string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	otherInfo, err := os.Stat(other)
+	return err == nil && os.SameFile(info, otherInfo)
+}
+
+// EmbeddedScript is a script embedded in a standalone executable along with the
+// options it was built with.
+type EmbeddedScript struct {
+	// Name labels errors from the script.
+	Name    string       `json:"name"`
+	Script  []byte       `json:"script"`
+	Options ParseOptions `json:"options"`
+}
+
+// AppendScript returns executable with embedded appended to its end.
+func AppendScript(executable []byte, embedded EmbeddedScript) ([]byte, error) {
+	payload, err := json.Marshal(embedded)
+	if err != nil {
+		return nil, err
+	}
+	standalone := append(bytes.Clone(executable), payload...)
+	standalone = binary.LittleEndian.AppendUint64(standalone, uint64(len(payload)))
+	return append(standalone, standaloneMagic...), nil
+}
+
+// ReadEmbeddedScript returns the script embedded in executable by [AppendScript],
+// or false if there isn't one. Only the end of executable is read.
+func ReadEmbeddedScript(executable string) (EmbeddedScript, bool, error) {
+	file, err := os.Open(executable)
+	if err != nil {
+		return EmbeddedScript{}, false, err
+	}
+	defer file.Close() //nolint:errcheck
+	info, err := file.Stat()
+	if err != nil {
+		return EmbeddedScript{}, false, err
+	}
+
+	trailer := make([]byte, 8+len(standaloneMagic))
+	size := info.Size() - int64(len(trailer))
+	if size < 0 {
+		return EmbeddedScript{}, false, nil
+	}
+	if _, err := file.ReadAt(trailer, size); err != nil {
+		return EmbeddedScript{}, false, err
+	}
+	if string(trailer[8:]) != standaloneMagic {
+		return EmbeddedScript{}, false, nil
+	}
+	length := binary.LittleEndian.Uint64(trailer)
+	if length > uint64(size) {
+		return EmbeddedScript{}, false, errors.New("reading embedded script: bad length")
+	}
+
+	payload := make([]byte, length)
+	if _, err := file.ReadAt(payload, size-int64(length)); err != nil {
+		return EmbeddedScript{}, false, err
+	}
+	var embedded EmbeddedScript
+	if err := json.Unmarshal(payload, &embedded); err != nil {
+		return EmbeddedScript{}, false, fmt.Errorf("reading embedded script: %w", err)
+	}
+	return embedded, true, nil
+}
+
+// standaloneSize is how many bytes at the end of executable were added by
+// [AppendScript], or 0 if it has no script.
+func standaloneSize(executable []byte) int {
+	trailer := 8 + len(standaloneMagic)
+	if len(executable) < trailer || string(executable[len(executable)-len(standaloneMagic):]) != standaloneMagic {
+		return 0
+	}
+	length := binary.LittleEndian.Uint64(executable[len(executable)-trailer:])
+	if length > uint64(len(executable)-trailer) {
+		return 0
+	}
+	return trailer + int(length)
+}
+
+// Standalone plays the script embedded in a standalone executable. It is parsed
+// with the options it was built with.
+type Standalone struct {
+	Optimize        bool            `negatable:"" default:"true" help:"Enable optimizations (default: enabled)"`
+	OptimizeOptions OptimizeOptions `embed:""`
+	RunOptions      RunOptions      `embed:""`
+
+	Embedded EmbeddedScript `kong:"-"`
+}
+
+func (cmd *Standalone) Run(ctx context.Context, stdout io.Writer) error {
+	compile := Compile{
+		Optimize:        cmd.Optimize,
+		ParseOptions:    cmd.Embedded.Options,
+		OptimizeOptions: cmd.OptimizeOptions,
+		RunOptions:      cmd.RunOptions,
+	}
+	input := io.NopCloser(bytes.NewReader(cmd.Embedded.Script))
+	err := compile.runScene(ctx, stdout, input, false)
+	if errors.Is(err, ErrQuit) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Embedded.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package compile_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "textly")
+	require.NoError(os.WriteFile(executable, []byte("binary"), 0o755))
+	output := filepath.Join(dir, "intro")
+
+	build := func(from string, script string) error {
+		cmd := compile.Build{
+			Input:        compile.StdinInput,
+			Output:       output,
+			Standalone:   true,
+			ParseOptions: compile.ParseOptions{Shell: true},
+			Stdin:        strings.NewReader(script),
+			Executable:   from,
+		}
+		return cmd.Run(t.Context(), &bytes.Buffer{})
+	}
+	embedded := func(path string) compile.EmbeddedScript {
+		embedded, ok, err := compile.ReadEmbeddedScript(path)
+		require.NoError(err)
+		require.True(ok)
+		return embedded
+	}
+
+	require.NoError(build(executable, "one"))
+	data, err := os.ReadFile(output)
+	require.NoError(err)
+	require.True(bytes.HasPrefix(data, []byte("binary")))
+	require.Equal(compile.EmbeddedScript{
+		Name:    "intro",
+		Script:  []byte("one"),
+		Options: compile.ParseOptions{Shell: true},
+	}, embedded(output))
+	_, ok, err := compile.ReadEmbeddedScript(executable)
+	require.NoError(err)
+	require.False(ok)
+
+	// Building from a standalone executable replaces its script
+	copied := filepath.Join(dir, "copy")
+	require.NoError(os.WriteFile(copied, data, 0o755))
+	require.NoError(build(copied, "two"))
+	data, err = os.ReadFile(output)
+	require.NoError(err)
+	require.True(bytes.HasPrefix(data, []byte("binary")))
+	require.Equal("two", string(embedded(output).Script))
+
+	require.ErrorContains(build(executable, "{nope}"), "unknown command")
+
+	// An existing output is made executable
+	require.NoError(os.Chmod(output, 0o644))
+	require.NoError(build(executable, "three"))
+	info, err := os.Stat(output)
+	require.NoError(err)
+	require.Equal(os.FileMode(0o755), info.Mode().Perm())
+
+	// A script without an extension isn't overwritten by its own executable
+	t.Chdir(dir)
+	require.NoError(os.WriteFile("intro", []byte("script"), 0o644))
+	cmd := compile.Build{Input: "intro", Standalone: true, Executable: executable}
+	require.ErrorContains(cmd.Run(t.Context(), &bytes.Buffer{}), "refusing to overwrite")
+	data, err = os.ReadFile("intro")
+	require.NoError(err)
+	require.Equal("script", string(data))
+	require.ErrorContains((&compile.Build{Input: "x"}).Run(t.Context(), &bytes.Buffer{}), "--standalone")
+}
+
+func TestStandalone(t *testing.T) {
+	require := require.New(t)
+	play := func(embedded compile.EmbeddedScript) (string, error) {
+		var output bytes.Buffer
+		cmd := compile.Standalone{
+			Optimize:   true,
+			RunOptions: compile.RunOptions{Output: compile.OutputPlain},
+			Embedded:   embedded,
+		}
+		err := cmd.Run(t.Context(), &output)
+		return output.String(), err
+	}
+
+	// Options from the build apply when the script is played
+	output, err := play(compile.EmbeddedScript{
+		Name:    "intro",
+		Script:  []byte("{$ echo hi}{$a}"),
+		Options: compile.ParseOptions{Shell: true, Vars: map[string]string{"a": "b"}},
+	})
+	require.NoError(err)
+	require.Equal("hi\nb", output)
+
+	_, err = play(compile.EmbeddedScript{Name: "intro", Script: []byte("{nope}")})
+	require.ErrorContains(err, "intro: invalid command")
+}
@@ -0,0 +1,166 @@
+package compile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ColorKind uint8
+
+const (
+	ColorUnset   ColorKind = iota
+	ColorBasic             // One of the 16 basic colors
+	ColorPalette           // One of the 256 palette colors
+	ColorRGB
+)
+
+type Color struct {
+	Kind  ColorKind
+	Index int    // For ColorBasic and ColorPalette
+	RGB   [3]int // For ColorRGB
+}
+
+func Basic(index int) Color {
+	return Color{Kind: ColorBasic, Index: index}
+}
+
+func Palette(index int) Color {
+	return Color{Kind: ColorPalette, Index: index}
+}
+
+func RGB(red, green, blue int) Color {
+	return Color{Kind: ColorRGB, RGB: [3]int{red, green, blue}}
+}
+
+// params are the SGR parameters for the color in profile. background selects 48 over 38.
+func (color Color) params(background bool, profile ColorProfile) []string {
+	switch color.Kind {
+	case ColorBasic:
+		code := 30 + color.Index
+		if color.Index >= 8 {
+			code = 90 + color.Index - 8
+		}
+		if background {
+			code += 10
+		}
+		return []string{strconv.Itoa(code)}
+	case ColorPalette:
+		if profile >= Profile256 {
+			code := "38"
+			if background {
+				code = "48"
+			}
+			return []string{code, "5", strconv.Itoa(color.Index)}
+		}
+		return colorParams(paletteRGB(color.Index), background, profile)
+	case ColorRGB:
+		return colorParams(color.RGB, background, profile)
+	default:
+		return nil
+	}
+}
+
+func (color Color) String() string {
+	switch color.Kind {
+	case ColorBasic:
+		return fmt.Sprintf("ansi(%d)", color.Index)
+	case ColorPalette:
+		return fmt.Sprintf("color(%d)", color.Index)
+	case ColorRGB:
+		return fmt.Sprintf("#%02x%02x%02x", color.RGB[0], color.RGB[1], color.RGB[2])
+	default:
+		return "unset"
+	}
+}
+
+// Attribute is a bit set of text attributes.
+type Attribute uint8
+
+const (
+	Bold Attribute = 1 << iota
+	Dim
+	Italic
+	Underline
+	Blink
+	Inverse
+	Strike
+)
+
+var attributes = []struct {
+	attribute Attribute
+	name      string
+	code      string
+}{
+	{Bold, "bold", "1"},
+	{Dim, "dim", "2"},
+	{Italic, "italic", "3"},
+	{Underline, "underline", "4"},
+	{Blink, "blink", "5"},
+	{Inverse, "inverse", "7"},
+	{Strike, "strike", "9"},
+}
+
+// Style is a set of attributes and colors. Unset colors leave the current color alone
+// so styles can be layered with With.
+type Style struct {
+	Foreground Color
+	Background Color
+	Attributes Attribute
+}
+
+// With layers other on top of style.
+func (style Style) With(other Style) Style {
+	if other.Foreground.Kind != ColorUnset {
+		style.Foreground = other.Foreground
+	}
+	if other.Background.Kind != ColorUnset {
+		style.Background = other.Background
+	}
+	style.Attributes |= other.Attributes
+	return style
+}
+
+// SGR is the escape sequence that resets the terminal then applies the style, limited to profile.
+func (style Style) SGR(profile ColorProfile) string {
+	if profile == ProfileNone {
+		return ""
+	}
+	params := []string{"0"}
+	for _, attribute := range attributes {
+		if style.Attributes&attribute.attribute != 0 {
+			params = append(params, attribute.code)
+		}
+	}
+	params = append(params, style.Foreground.params(false, profile)...)
+	params = append(params, style.Background.params(true, profile)...)
+	if len(params) == 1 {
+		return Reset
+	}
+	return "\033[" + strings.Join(params, ";") + "m"
+}
+
+// visibleBlank is whether a space drawn in the style can be told apart from an unstyled one.
+func (style Style) visibleBlank() bool {
+	return style.Background.Kind != ColorUnset || style.Attributes&(Underline|Inverse|Strike) != 0
+}
+
+func (style Style) String() string {
+	var parts []string
+	for _, attribute := range attributes {
+		if style.Attributes&attribute.attribute != 0 {
+			parts = append(parts, attribute.name)
+		}
+	}
+	if style.Foreground.Kind != ColorUnset {
+		parts = append(parts, "fg="+style.Foreground.String())
+	}
+	if style.Background.Kind != ColorUnset {
+		parts = append(parts, "bg="+style.Background.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (style Style) MarshalText() ([]byte, error) {
+	return []byte(style.String()), nil
+}
@@ -0,0 +1,181 @@
+package compile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	OutputSRT = "srt"
+	OutputVTT = "vtt"
+)
+
+// subtitleLinger is how long the last caption stays up after the program is done.
+const subtitleLinger = 2 * time.Second
+
+// Cue is a single caption.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// SubtitleRenderer writes captions in SRT or WebVTT format to Writer, if set, once the program
+// is done. Each line becomes a cue once it ends, starting from when it was last typed and
+// lasting until the next cue starts or the screen is cleared. Only text left on screen is captioned.
+type SubtitleRenderer struct {
+	Writer io.Writer
+	Clock  Clock
+	// Format is OutputSRT or OutputVTT.
+	Format string
+	Cues   []Cue
+
+	start  time.Time
+	screen Screen
+	edited time.Duration // When the current line last changed
+	rows   []int         // Row of each cue
+	open   bool          // Whether the last cue has not ended yet
+}
+
+func NewSubtitleRenderer(writer io.Writer, clock Clock, format string) *SubtitleRenderer {
+	return &SubtitleRenderer{
+		Writer: writer,
+		Clock:  clock,
+		Format: format,
+		start:  clock.Now(),
+	}
+}
+
+func (renderer *SubtitleRenderer) now() time.Duration {
+	return renderer.Clock.Now().Sub(renderer.start)
+}
+
+func (renderer *SubtitleRenderer) edit() {
+	renderer.edited = renderer.now()
+}
+
+// finish turns the current line into a cue.
+func (renderer *SubtitleRenderer) finish() {
+	text := strings.TrimSpace(string(renderer.screen.line(renderer.screen.Row)))
+	if text == "" {
+		return
+	}
+	renderer.end(renderer.edited)
+	renderer.Cues = append(renderer.Cues, Cue{Start: renderer.edited, Text: text})
+	renderer.rows = append(renderer.rows, renderer.screen.Row)
+	renderer.open = true
+}
+
+// end ends the last cue at.
+func (renderer *SubtitleRenderer) end(at time.Duration) {
+	if renderer.open {
+		last := &renderer.Cues[len(renderer.Cues)-1]
+		last.End = max(at, last.Start)
+		renderer.open = false
+	}
+}
+
+func (renderer *SubtitleRenderer) Print(text string) error {
+	for _, char := range text {
+		renderer.screen.Print(char)
+	}
+	renderer.edit()
+	return nil
+}
+
+func (renderer *SubtitleRenderer) Newline() error {
+	renderer.finish()
+	renderer.screen.Newline()
+	renderer.edit()
+	return nil
+}
+
+func (renderer *SubtitleRenderer) Delete(count int) error {
+	renderer.screen.Delete(count)
+	renderer.edit()
+	return nil
+}
+
+// DeleteNewline takes back the cue of the line being joined onto.
+func (renderer *SubtitleRenderer) DeleteNewline(column int) error {
+	renderer.screen.Delete(1)
+	if last := len(renderer.Cues) - 1; renderer.open && renderer.rows[last] == renderer.screen.Row {
+		taken := renderer.Cues[last]
+		renderer.Cues = renderer.Cues[:last]
+		renderer.rows = renderer.rows[:last]
+		// Reopen the cue it ended
+		renderer.open = last > 0 && renderer.Cues[last-1].End == taken.Start
+	}
+	renderer.edit()
+	return nil
+}
+
+func (renderer *SubtitleRenderer) Clear() error {
+	renderer.finish()
+	renderer.end(renderer.now())
+	renderer.screen.Clear()
+	renderer.edit()
+	return nil
+}
+
+func (renderer *SubtitleRenderer) MoveCursor(cursor Cursor) error {
+	renderer.screen.Move(cursor)
+	return nil
+}
+
+func (renderer *SubtitleRenderer) Erase(erase Erase) error {
+	renderer.screen.Erase(erase)
+	renderer.edit()
+	return nil
+}
+
+func (renderer *SubtitleRenderer) PushStyle(style Style) error {
+	return nil
+}
+
+func (renderer *SubtitleRenderer) PopStyle() error {
+	return nil
+}
+
+func (renderer *SubtitleRenderer) Sleep(duration time.Duration) error {
+	return nil
+}
+
+func (renderer *SubtitleRenderer) Flush() error {
+	renderer.finish()
+	if renderer.open {
+		renderer.end(max(renderer.now(), renderer.Cues[len(renderer.Cues)-1].Start+subtitleLinger))
+	}
+
+	if renderer.Writer == nil {
+		return nil
+	}
+	var builder strings.Builder
+	if renderer.Format == OutputVTT {
+		builder.WriteString("WEBVTT\n\n")
+	}
+	for i, cue := range renderer.Cues {
+		if renderer.Format == OutputVTT {
+			fmt.Fprintf(&builder, "%s --> %s\n%s\n\n", timestamp(cue.Start, "."), timestamp(cue.End, "."), cue.Text)
+		} else {
+			fmt.Fprintf(&builder, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(cue.Start, ","), timestamp(cue.End, ","), cue.Text)
+		}
+	}
+	_, err := io.WriteString(renderer.Writer, builder.String())
+	return err
+}
+
+// timestamp formats duration as hours:minutes:seconds then the fraction separator and milliseconds.
+func timestamp(duration time.Duration, separator string) string {
+	milliseconds := duration.Milliseconds()
+	return fmt.Sprintf(
+		"%02d:%02d:%02d%s%03d",
+		milliseconds/3_600_000,
+		milliseconds/60_000%60,
+		milliseconds/1000%60,
+		separator,
+		milliseconds%1000,
+	)
+}
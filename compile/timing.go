@@ -0,0 +1,82 @@
+package compile
+
+import (
+	"math/rand/v2"
+	"time"
+	"unicode"
+)
+
+// Timing decides how long to wait after typing char when next is about to be typed.
+// next is 0 when it is not known yet.
+type Timing interface {
+	Delay(char, next rune) time.Duration
+}
+
+type TimingOptions struct {
+	WPM    float64 `help:"Typing speed in words per minute. Overrides --delay."`
+	Human  bool    `help:"Type like a person: jittered delays, pauses after punctuation and newlines and slower starts of words."`
+	Jitter float64 `default:"0.35" help:"Standard deviation of each --human delay as a fraction of it."`
+}
+
+// NewTiming builds the timing model selected by options.
+func NewTiming(options RunOptions) Timing {
+	base := options.Delay
+	if options.WPM > 0 {
+		// A word is 5 characters by convention
+		base = time.Duration(float64(time.Minute) / (options.WPM * 5))
+	}
+	if !options.Human {
+		return FixedTiming(base)
+	}
+
+	seed := options.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &HumanTiming{
+		Base:   base,
+		Jitter: options.Jitter,
+		Rand:   rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// FixedTiming waits the same amount after every character.
+type FixedTiming time.Duration
+
+func (timing FixedTiming) Delay(char, next rune) time.Duration {
+	return time.Duration(timing)
+}
+
+// HumanTiming adds gaussian jitter to Base, pauses after punctuation and newlines, and
+// slows down at the start of words.
+type HumanTiming struct {
+	Base   time.Duration
+	Jitter float64
+	Rand   *rand.Rand
+}
+
+var humanPauses = map[rune]float64{
+	'.':  8,
+	'!':  8,
+	'?':  8,
+	'\n': 6,
+	',':  4,
+	';':  4,
+	':':  4,
+}
+
+const wordStartPause = 2
+
+func (timing *HumanTiming) Delay(char, next rune) time.Duration {
+	scale := 1.0
+	if pause, ok := humanPauses[char]; ok {
+		scale = pause
+	} else if unicode.IsSpace(char) && unicode.IsLetter(next) {
+		scale = wordStartPause
+	}
+
+	scale *= 1 + timing.Jitter*timing.Rand.NormFloat64()
+	// Never type faster than a fifth of the base delay
+	scale = max(scale, 0.2)
+	return time.Duration(float64(timing.Base) * scale)
+}
@@ -0,0 +1,92 @@
+package compile_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTiming(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  RunOptions
+		char     rune
+		next     rune
+		expected time.Duration
+	}{
+		{
+			name:     "fixed delay",
+			options:  RunOptions{Delay: 10 * time.Millisecond},
+			char:     'a',
+			expected: 10 * time.Millisecond,
+		},
+		{
+			name:     "fixed ignores punctuation",
+			options:  RunOptions{Delay: 10 * time.Millisecond},
+			char:     '.',
+			expected: 10 * time.Millisecond,
+		},
+		{
+			name:     "words per minute",
+			options:  RunOptions{Delay: time.Second, TimingOptions: TimingOptions{WPM: 60}},
+			char:     'a',
+			expected: 200 * time.Millisecond,
+		},
+		{
+			name:     "human without jitter",
+			options:  RunOptions{Delay: 10 * time.Millisecond, TimingOptions: TimingOptions{Human: true}},
+			char:     'a',
+			next:     'b',
+			expected: 10 * time.Millisecond,
+		},
+		{
+			name:     "human pauses after a sentence",
+			options:  RunOptions{Delay: 10 * time.Millisecond, TimingOptions: TimingOptions{Human: true}},
+			char:     '.',
+			expected: 80 * time.Millisecond,
+		},
+		{
+			name:     "human pauses after a newline",
+			options:  RunOptions{Delay: 10 * time.Millisecond, TimingOptions: TimingOptions{Human: true}},
+			char:     '\n',
+			expected: 60 * time.Millisecond,
+		},
+		{
+			name:     "human slows down before a word",
+			options:  RunOptions{Delay: 10 * time.Millisecond, TimingOptions: TimingOptions{Human: true}},
+			char:     ' ',
+			next:     'w',
+			expected: 20 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timing := NewTiming(tt.options)
+			assert.Equal(t, tt.expected, timing.Delay(tt.char, tt.next))
+		})
+	}
+}
+
+func TestHumanTimingSeed(t *testing.T) {
+	delays := func(seed uint64) []time.Duration {
+		timing := NewTiming(RunOptions{
+			Delay:         10 * time.Millisecond,
+			TimingOptions: TimingOptions{Human: true, Jitter: 0.35},
+			Seed:          seed,
+		})
+		var delays []time.Duration
+		for _, char := range "hello world" {
+			delays = append(delays, timing.Delay(char, 0))
+		}
+		return delays
+	}
+
+	assert.Equal(t, delays(1), delays(1))
+	assert.NotEqual(t, delays(1), delays(2))
+	for _, delay := range delays(3) {
+		assert.GreaterOrEqual(t, delay, 2*time.Millisecond)
+	}
+}
@@ -0,0 +1,138 @@
+package compile
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Typewriter is an io.Writer that types everything written to it out to Writer
+// a character at a time, waiting as the program would between characters.
+// ANSI escape sequences are written whole without waiting.
+type Typewriter struct {
+	Writer   io.Writer
+	timing   Timing
+	controls *controls
+	// pending holds an escape sequence or character split across writes
+	pending []byte
+}
+
+// NewTypewriter types to writer using the timing, clock, keys and done channel from options.
+// Controls is ignored, only Keys are read.
+func NewTypewriter(writer io.Writer, options RunOptions) *Typewriter {
+	clock := options.Clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+	timing := options.Timing
+	if timing == nil {
+		timing = NewTiming(options)
+	}
+	controls := newControls(options.Keys, clock)
+	controls.done = options.Done
+	controls.pause = options.Pause
+	return &Typewriter{
+		Writer:   writer,
+		timing:   timing,
+		controls: controls,
+	}
+}
+
+// Write returns once all of p has been typed. If it is stopped early the count
+// only includes what was typed.
+func (typewriter *Typewriter) Write(p []byte) (int, error) {
+	buffered := len(typewriter.pending)
+	data := append(typewriter.pending, p...)
+	typewriter.pending = nil
+
+	written := 0
+	for written < len(data) {
+		size, complete := nextChunk(data[written:])
+		if !complete {
+			typewriter.pending = data[written:]
+			break
+		}
+		chunk := data[written : written+size]
+		if _, err := typewriter.Writer.Write(chunk); err != nil {
+			return max(written-buffered, 0), err
+		}
+		written += size
+		if chunk[0] == '\033' {
+			continue
+		}
+
+		char, _ := utf8.DecodeRune(chunk)
+		next, _ := utf8.DecodeRune(data[written:])
+		if next == utf8.RuneError {
+			next = 0
+		}
+		if err := typewriter.controls.next(); err != nil {
+			return max(written-buffered, 0), err
+		}
+		if err := typewriter.controls.wait(typewriter.timing.Delay(char, next)); err != nil {
+			return max(written-buffered, 0), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes anything held back waiting for the rest of an escape sequence or character.
+func (typewriter *Typewriter) Flush() error {
+	if len(typewriter.pending) == 0 {
+		return nil
+	}
+	_, err := typewriter.Writer.Write(typewriter.pending)
+	typewriter.pending = nil
+	return err
+}
+
+// maxEscapeSize is the longest escape sequence held back waiting for its end. Anything
+// longer is written as it is so an unterminated sequence can't swallow the rest.
+const maxEscapeSize = 4096
+
+// nextChunk returns the length of the escape sequence or character at the start of
+// data, and false if data ends before it does.
+func nextChunk(data []byte) (int, bool) {
+	if data[0] != '\033' {
+		if !utf8.FullRune(data) {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(data)
+		return size, true
+	}
+	if len(data) < 2 {
+		return 0, false
+	}
+
+	end := min(len(data), maxEscapeSize)
+	switch data[1] {
+	case '[':
+		// CSI: parameters and intermediates up to a final byte from @ to ~
+		for i := 2; i < end; i++ {
+			if data[i] >= 0x40 && data[i] <= 0x7e {
+				return i + 1, true
+			}
+		}
+	case ']':
+		// OSC: ended by BEL or ESC \
+		for i := 2; i < end; i++ {
+			if data[i] == '\a' {
+				return i + 1, true
+			}
+			if data[i] == '\033' && i+1 < len(data) && data[i+1] == '\\' {
+				return i + 2, true
+			}
+		}
+	default:
+		// Intermediates from space to / then a final byte, such as ESC ( B to pick a
+		// character set or just ESC 7 to save the cursor
+		for i := 1; i < end; i++ {
+			if data[i] < 0x20 || data[i] > 0x2f {
+				return i + 1, true
+			}
+		}
+	}
+	if len(data) >= maxEscapeSize {
+		return maxEscapeSize, true
+	}
+	return 0, false
+}
@@ -0,0 +1,101 @@
+package compile_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypewriter(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		writes   []string
+		expected []compile.TimedWrite
+	}{
+		{
+			name:   "a character at a time",
+			writes: []string{"hi\n"},
+			expected: []compile.TimedWrite{
+				{At: 0, Data: "h"},
+				{At: 10 * ms, Data: "i"},
+				{At: 20 * ms, Data: "\n"},
+			},
+		},
+		{
+			name:   "escape sequences are written whole",
+			writes: []string{"\033[1;31ma\033[0m\0337\033]0;title\a"},
+			expected: []compile.TimedWrite{
+				{At: 0, Data: "\033[1;31m"},
+				{At: 0, Data: "a"},
+				{At: 10 * ms, Data: "\033[0m"},
+				{At: 10 * ms, Data: "\0337"},
+				{At: 10 * ms, Data: "\033]0;title\a"},
+			},
+		},
+		{
+			name:   "escape sequences with intermediates",
+			writes: []string{"\033(", "Ba"},
+			expected: []compile.TimedWrite{
+				{At: 0, Data: "\033(B"},
+				{At: 0, Data: "a"},
+			},
+		},
+		{
+			name:   "unterminated escape sequences are cut off",
+			writes: []string{"\033]0;" + strings.Repeat("x", 4092) + "ab"},
+			expected: []compile.TimedWrite{
+				{At: 0, Data: "\033]0;" + strings.Repeat("x", 4092)},
+				{At: 0, Data: "a"},
+				{At: 10 * ms, Data: "b"},
+			},
+		},
+		{
+			name:   "escape sequences and characters split across writes",
+			writes: []string{"\033[3", "2mé"[:3], "é"[1:] + "!"},
+			expected: []compile.TimedWrite{
+				{At: 0, Data: "\033[32m"},
+				{At: 0, Data: "é"},
+				{At: 10 * ms, Data: "!"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			clock := compile.NewVirtualClock()
+			recorder := &compile.Recorder{Clock: clock}
+			typewriter := compile.NewTypewriter(recorder, compile.RunOptions{
+				Delay: 10 * ms,
+				Clock: clock,
+			})
+			for _, write := range test.writes {
+				n, err := typewriter.Write([]byte(write))
+				require.NoError(err)
+				require.Equal(len(write), n)
+			}
+			require.NoError(typewriter.Flush())
+			require.Equal(test.expected, recorder.Writes)
+		})
+	}
+}
+
+func TestTypewriterStopped(t *testing.T) {
+	require := require.New(t)
+	done := make(chan struct{})
+	close(done)
+	recorder := &compile.Recorder{Clock: compile.NewVirtualClock()}
+	typewriter := compile.NewTypewriter(recorder, compile.RunOptions{
+		Delay: time.Hour,
+		Done:  done,
+	})
+
+	n, err := typewriter.Write([]byte("abc"))
+	require.ErrorIs(err, compile.ErrStopped)
+	require.Equal(1, n)
+	require.Equal("a", recorder.String())
+}
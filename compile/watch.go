@@ -0,0 +1,148 @@
+package compile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the script has to stop changing before it is replayed.
+const watchDebounce = 100 * time.Millisecond
+
+// watch replays the script every time it is saved until it is quit with q or ctx is done.
+func (cmd *Compile) watch(ctx context.Context, stdout io.Writer) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close() //nolint:errcheck
+
+	paths := map[string]bool{}
+	for _, input := range cmd.Input {
+		if input == StdinInput {
+			return errors.New("can not watch stdin")
+		}
+		path, err := filepath.Abs(input)
+		if err != nil {
+			return err
+		}
+		paths[path] = true
+		// Watch the directory since editors often replace the file instead of writing to it
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+	changes := debounce(watcher, paths)
+
+	terminal := IsTerminal(stdout)
+	if cmd.RunOptions.Keys == nil && cmd.RunOptions.Controls && !exportedOutput(cmd.RunOptions.Output) && keyboardAvailable(stdout) {
+		keyboard, err := OpenKeyboard()
+		if err != nil {
+			return fmt.Errorf("enabling keyboard controls: %w", err)
+		}
+		defer keyboard.Close() //nolint:errcheck
+		cmd.RunOptions.Keys = keyboard.Keys
+		stdout = RawWriter{stdout}
+	}
+
+	for {
+		stop := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.play(ctx, stdout, stop)
+		}()
+
+		select {
+		case <-ctx.Done():
+			close(stop)
+			<-done
+			return nil
+		case <-changes:
+			close(stop)
+			<-done
+			continue
+		case err := <-done:
+			if errors.Is(err, ErrQuit) {
+				return nil
+			} else if err != nil {
+				if terminal {
+					io.WriteString(stdout, ClearANSI) //nolint:errcheck
+				}
+				fmt.Fprintf(stdout, "error: %s\n", err) //nolint:errcheck
+			}
+		}
+
+		if quit := waitForChange(ctx, changes, cmd.RunOptions.Keys); quit {
+			return nil
+		}
+	}
+}
+
+// waitForChange blocks until the script changes. It is true if the watch should end instead.
+func waitForChange(ctx context.Context, changes <-chan struct{}, keys <-chan Key) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-changes:
+			return false
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+			} else if key == 'q' || key == KeyInterrupt {
+				return true
+			}
+		}
+	}
+}
+
+// play runs the scripts once, stopping early when stop is closed.
+func (cmd *Compile) play(ctx context.Context, stdout io.Writer, stop <-chan struct{}) error {
+	replay := *cmd
+	replay.RunOptions.Done = stop
+	// Let the replay finish once stopped so it never draws over the next one
+	if err := replay.run(context.WithoutCancel(ctx), stdout); err != nil {
+		return err
+	}
+	select {
+	case <-stop:
+		return ErrStopped
+	default:
+		return nil
+	}
+}
+
+// debounce sends on the returned channel once paths have stopped changing.
+func debounce(watcher *fsnotify.Watcher, paths map[string]bool) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	go func() {
+		var timer <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if paths[event.Name] && !event.Has(fsnotify.Chmod) {
+					timer = time.After(watchDebounce)
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			case <-timer:
+				timer = nil
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes
+}
@@ -0,0 +1,74 @@
+package compile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is written to by a watch while the test reads it.
+type syncBuffer struct {
+	mutex   sync.Mutex
+	builder strings.Builder
+}
+
+func (buffer *syncBuffer) Write(p []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.builder.Write(p)
+}
+
+func (buffer *syncBuffer) String() string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.builder.String()
+}
+
+func TestWatch(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "script.txt")
+	require.NoError(os.WriteFile(path, []byte("one"), 0o644))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	var output syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		cmd := compile.Compile{
+			Input:    []string{path},
+			Watch:    true,
+			Optimize: true,
+			RunOptions: compile.RunOptions{
+				Output: compile.OutputPlain,
+			},
+		}
+		done <- cmd.Run(ctx, &output)
+	}()
+
+	eventually := func(expected string) {
+		require.Eventually(func() bool {
+			return strings.Contains(output.String(), expected)
+		}, 5*time.Second, 10*time.Millisecond, "expected %q in %q", expected, output.String())
+	}
+	eventually("one")
+
+	require.NoError(os.WriteFile(path, []byte("two"), 0o644))
+	eventually("two")
+
+	require.NoError(os.WriteFile(path, []byte("{nope}"), 0o644))
+	eventually(`error: script: invalid command: unknown command: "nope"`)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop")
+	}
+}
@@ -4,14 +4,15 @@ go 1.25.2
 
 require (
 	github.com/alecthomas/kong v1.13.0
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/ohhfishal/gopher v0.6.1
 	github.com/stretchr/testify v1.11.1
+	golang.org/x/term v0.37.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,5 +1,7 @@
 //go:build gopher
 
+// Build targets for gopher, run from the repository root with gopher -C gopher/gopher.go.
+// It lives in its own directory since the repository root is the textly package.
 package main
 
 import (
@@ -15,7 +17,8 @@ func Devel(ctx context.Context, args RunArgs) error {
 	return Run(ctx, NowAnd(OnFileChange(1*time.Second, ".go")),
 		&Printer{},
 		&GoBuild{
-			Output: "target/dev",
+			Output:   "target/dev",
+			Packages: []string{"./cmd/textly"},
 		},
 		&GoFormat{},
 		&GoTest{},
@@ -31,7 +34,8 @@ func CICD(ctx context.Context, args RunArgs) error {
 	return Run(ctx, Now(),
 		&Printer{},
 		&GoBuild{
-			Output: "target/cicd",
+			Output:   "target/cicd",
+			Packages: []string{"./cmd/textly"},
 		},
 		&GoFormat{
 			CheckOnly: true,
@@ -0,0 +1,145 @@
+package textly
+
+import (
+	"time"
+
+	"github.com/ohhfishal/textly/compile"
+)
+
+// Option configures how a script is compiled and played.
+type Option func(*config)
+
+type config struct {
+	parse    compile.ParseOptions
+	run      compile.RunOptions
+	optimize bool
+}
+
+// newConfig applies opts on top of the same defaults as the command line.
+// Keyboard controls are off unless enabled with [WithControls].
+func newConfig(opts []Option) config {
+	config := config{
+		run: compile.RunOptions{
+			Delay:        50 * time.Millisecond,
+			Beat:         time.Second,
+			Wait:         time.Second,
+			Output:       compile.OutputANSI,
+			Color:        compile.ColorAuto,
+			SpeakCommand: "espeak",
+			TimingOptions: compile.TimingOptions{
+				Jitter: 0.35,
+			},
+		},
+		optimize: true,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	config.run.Seed = config.parse.Seed
+	return config
+}
+
+// WithDelay sets how long to wait after each character.
+func WithDelay(delay time.Duration) Option {
+	return func(config *config) {
+		config.run.Delay = delay
+	}
+}
+
+// WithBeat sets how long {sleep N} waits for each N.
+func WithBeat(beat time.Duration) Option {
+	return func(config *config) {
+		config.run.Beat = beat
+	}
+}
+
+// WithWPM sets the typing speed in words per minute, overriding [WithDelay].
+func WithWPM(wpm float64) Option {
+	return func(config *config) {
+		config.run.WPM = wpm
+	}
+}
+
+// WithHuman types like a person with jittered delays.
+func WithHuman() Option {
+	return func(config *config) {
+		config.run.Human = true
+	}
+}
+
+// WithSeed makes random choices and --human delays repeatable.
+func WithSeed(seed uint64) Option {
+	return func(config *config) {
+		config.parse.Seed = seed
+	}
+}
+
+// WithOutput picks how the animation is drawn, one of ansi, plain, events, srt or vtt.
+func WithOutput(output string) Option {
+	return func(config *config) {
+		config.run.Output = output
+	}
+}
+
+// WithColor sets when to use colors, one of auto, always or never.
+func WithColor(color string) Option {
+	return func(config *config) {
+		config.run.Color = color
+	}
+}
+
+// WithVar sets the script variable name, as if passed with --var.
+func WithVar(name string, value string) Option {
+	return func(config *config) {
+		if config.parse.Vars == nil {
+			config.parse.Vars = map[string]string{}
+		}
+		config.parse.Vars[name] = value
+	}
+}
+
+// WithShell allows scripts to run shell commands in dir.
+func WithShell(dir string) Option {
+	return func(config *config) {
+		config.parse.Shell = true
+		config.parse.ShellDir = dir
+	}
+}
+
+// WithControls reads keyboard controls from stdin when both stdin and stdout are
+// terminals. On Unix stdin is only read while the program plays. Elsewhere a read
+// can't be cancelled, so once controls have been used a background reader keeps
+// taking input from stdin for the rest of the process.
+func WithControls() Option {
+	return func(config *config) {
+		config.run.Controls = true
+	}
+}
+
+// WithoutOptimize plays the program exactly as parsed.
+func WithoutOptimize() Option {
+	return func(config *config) {
+		config.optimize = false
+	}
+}
+
+// WithClock replaces the real clock, mostly for tests.
+func WithClock(clock compile.Clock) Option {
+	return func(config *config) {
+		config.run.Clock = clock
+	}
+}
+
+// WithRenderer draws with renderer instead of the one picked by [WithOutput].
+func WithRenderer(renderer compile.Renderer) Option {
+	return func(config *config) {
+		config.run.Renderer = renderer
+	}
+}
+
+// WithRunOptions replaces every playback option at once.
+func WithRunOptions(options compile.RunOptions) Option {
+	return func(config *config) {
+		config.run = options
+	}
+}
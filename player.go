@@ -0,0 +1,121 @@
+package textly
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/ohhfishal/textly/compile"
+)
+
+// Player plays a program in the background so it can be paused and stopped.
+type Player struct {
+	program *Program
+	stdout  io.Writer
+	options compile.RunOptions
+
+	pause    chan bool
+	done     chan struct{}
+	finished chan struct{}
+	stop     sync.Once
+
+	mu      sync.Mutex
+	started bool
+	err     error
+}
+
+// NewPlayer prepares program to be played to stdout. Options that only affect
+// compiling are ignored.
+func NewPlayer(program *Program, stdout io.Writer, opts ...Option) *Player {
+	return newPlayer(program, stdout, newConfig(opts))
+}
+
+func newPlayer(program *Program, stdout io.Writer, config config) *Player {
+	return &Player{
+		program:  program,
+		stdout:   stdout,
+		options:  config.run,
+		pause:    make(chan bool),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+}
+
+// Start begins playing in the background. Cancelling ctx stops the player.
+func (player *Player) Start(ctx context.Context) error {
+	player.mu.Lock()
+	defer player.mu.Unlock()
+	if player.started {
+		return errors.New("player already started")
+	}
+	player.started = true
+
+	options := player.options
+	options.Pause = player.pause
+	options.Done = player.done
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			player.Stop() //nolint:errcheck
+		case <-player.finished:
+		}
+	}()
+	go func() {
+		// Closing finished publishes err to Wait
+		player.err = finished(player.program.Run(player.stdout, options))
+		close(player.finished)
+	}()
+	return nil
+}
+
+// send passes paused to the program, returning false if it isn't running.
+func (player *Player) send(paused bool) bool {
+	player.mu.Lock()
+	started := player.started
+	player.mu.Unlock()
+	if !started {
+		return false
+	}
+
+	select {
+	case player.pause <- paused:
+		return true
+	case <-player.finished:
+		return false
+	}
+}
+
+// Pause holds playback until Resume. It blocks until the program reaches its next
+// instruction or wait, and does nothing if the player isn't running.
+func (player *Player) Pause() {
+	player.send(true)
+}
+
+// Resume continues playback after Pause.
+func (player *Player) Resume() {
+	player.send(false)
+}
+
+// Stop ends playback and waits for the player to finish.
+func (player *Player) Stop() error {
+	player.stop.Do(func() {
+		close(player.done)
+	})
+	return player.Wait()
+}
+
+// Wait blocks until the program is done, quit or stopped. Only errors from
+// playing it are returned.
+func (player *Player) Wait() error {
+	player.mu.Lock()
+	started := player.started
+	player.mu.Unlock()
+	if !started {
+		return errors.New("player not started")
+	}
+
+	<-player.finished
+	return player.err
+}
@@ -0,0 +1,70 @@
+// Package textly plays textly scripts from Go programs.
+//
+//	err := textly.Play(ctx, strings.NewReader("Hello @bold{world}!"), os.Stdout)
+//
+// The command line tool lives in cmd/textly.
+package textly
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/ohhfishal/textly/compile"
+)
+
+// Program is a compiled script, ready to be played any number of times.
+type Program = compile.Program
+
+// Compile reads a whole script, including its header, and compiles it.
+func Compile(reader io.Reader, opts ...Option) (*Program, error) {
+	config := newConfig(opts)
+	return config.compile(reader)
+}
+
+// Play compiles the script read from reader and plays it to stdout, returning
+// once it is done, quit or ctx is cancelled.
+func Play(ctx context.Context, reader io.Reader, stdout io.Writer, opts ...Option) error {
+	config := newConfig(opts)
+	program, err := config.compile(reader)
+	if err != nil {
+		return err
+	}
+
+	player := newPlayer(program, stdout, config)
+	if err := player.Start(ctx); err != nil {
+		return err
+	}
+	if err := player.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (config config) compile(reader io.Reader) (*Program, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := compile.ReadHeader(buffered)
+	if err != nil {
+		return nil, err
+	}
+
+	// The context only stops the lexer early, the whole script is read either way
+	program, err := compile.ParseReader(context.Background(), buffered, config.parse.WithHeader(header))
+	if err != nil {
+		return nil, err
+	}
+	if config.optimize {
+		program.Optimize(compile.OptimizeOptions{})
+	}
+	return program, nil
+}
+
+// finished maps how a program ended to the error reported to callers.
+// Quitting with the keyboard and stopping are not errors.
+func finished(err error) error {
+	if errors.Is(err, compile.ErrQuit) || errors.Is(err, compile.ErrStopped) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package textly_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ohhfishal/textly"
+	"github.com/ohhfishal/textly/compile"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is written to by a player while the test reads it.
+type syncBuffer struct {
+	mutex   sync.Mutex
+	builder strings.Builder
+}
+
+func (buffer *syncBuffer) Write(p []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.builder.Write(p)
+}
+
+func (buffer *syncBuffer) String() string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.builder.String()
+}
+
+func TestCompile(t *testing.T) {
+	require := require.New(t)
+	script := "---\nvars:\n  name: header\n---\nHi {$name}"
+
+	program, err := textly.Compile(strings.NewReader(script))
+	require.NoError(err)
+	require.Equal([]compile.Instruction{
+		{Opcode: compile.OpPrint, Arg: "Hi header"},
+	}, program.Instructions)
+
+	program, err = textly.Compile(strings.NewReader(script), textly.WithVar("name", "option"), textly.WithoutOptimize())
+	require.NoError(err)
+	require.Equal(compile.Instruction{Opcode: compile.OpPrint, Arg: "option"}, program.Instructions[3])
+
+	_, err = textly.Compile(strings.NewReader("{nope}"))
+	require.ErrorContains(err, "unknown command")
+}
+
+func TestPlay(t *testing.T) {
+	require := require.New(t)
+	clock := compile.NewVirtualClock()
+	var output bytes.Buffer
+
+	err := textly.Play(t.Context(), strings.NewReader("Hello [world]there\n"), &output,
+		textly.WithOutput(compile.OutputPlain),
+		textly.WithClock(clock),
+	)
+	require.NoError(err)
+	require.Equal("Hello there\n", output.String())
+	require.Equal(22*50*time.Millisecond, clock.Elapsed())
+}
+
+func TestPlayWait(t *testing.T) {
+	require := require.New(t)
+	clock := compile.NewVirtualClock()
+	var output bytes.Buffer
+
+	// Without controls {wait} pauses for --wait instead of waiting for a key
+	err := textly.Play(t.Context(), strings.NewReader("a{wait}b\n"), &output,
+		textly.WithDelay(0),
+		textly.WithOutput(compile.OutputPlain),
+		textly.WithClock(clock),
+	)
+	require.NoError(err)
+	require.Equal("ab\n", output.String())
+	require.Equal(time.Second, clock.Elapsed())
+
+	program, err := textly.Compile(strings.NewReader("a{wait 10ms}b"))
+	require.NoError(err)
+	output.Reset()
+	player := textly.NewPlayer(program, &output, textly.WithDelay(0), textly.WithColor(compile.ColorNever))
+	require.NoError(player.Start(t.Context()))
+	require.NoError(player.Wait())
+	require.Equal("ab", output.String())
+}
+
+func TestPlayer(t *testing.T) {
+	require := require.New(t)
+	program, err := textly.Compile(strings.NewReader("abcdefghij{sleep 100}never"))
+	require.NoError(err)
+
+	var output syncBuffer
+	player := textly.NewPlayer(program, &output,
+		textly.WithDelay(10*time.Millisecond),
+		textly.WithColor(compile.ColorNever),
+	)
+	require.Error(player.Wait())
+	require.NoError(player.Start(t.Context()))
+	require.Error(player.Start(t.Context()))
+
+	player.Pause()
+	paused := output.String()
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(paused, output.String())
+	require.NotContains(paused, "j")
+
+	player.Resume()
+	require.Eventually(func() bool {
+		return strings.HasSuffix(output.String(), "j")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(player.Stop())
+	require.Equal("abcdefghij", output.String())
+}
+
+func TestTypewriter(t *testing.T) {
+	require := require.New(t)
+	clock := compile.NewVirtualClock()
+	var output bytes.Buffer
+
+	typewriter := textly.NewTypewriter(&output, textly.WithWPM(120), textly.WithClock(clock))
+	_, err := fmt.Fprintln(typewriter, "\033[1mhi\033[0m")
+	require.NoError(err)
+	require.NoError(typewriter.Flush())
+	require.Equal("\033[1mhi\033[0m\n", output.String())
+	// 120 words per minute is 100ms a character
+	require.Equal(300*time.Millisecond, clock.Elapsed())
+}
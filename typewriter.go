@@ -0,0 +1,21 @@
+package textly
+
+import (
+	"io"
+
+	"github.com/ohhfishal/textly/compile"
+)
+
+// Typewriter types anything written to it, see [NewTypewriter].
+type Typewriter = compile.Typewriter
+
+// NewTypewriter wraps stdout so everything written to it is typed out at the speed
+// set by opts. Escape sequences pass through whole. Call Flush once done in case
+// the last write ended partway through one. Keyboard controls from [WithControls]
+// aren't read since the typewriter never takes over stdin.
+//
+//	typewriter := textly.NewTypewriter(os.Stdout, textly.WithWPM(120), textly.WithHuman())
+//	fmt.Fprintln(typewriter, "Welcome to mytool")
+func NewTypewriter(stdout io.Writer, opts ...Option) *Typewriter {
+	return compile.NewTypewriter(stdout, newConfig(opts).run)
+}